@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,6 +15,44 @@ type Config struct {
 	GEMINI_API_KEY string
 	GEMINI_MODEL   string
 	EMBED_MODEL string
+
+	// LLM provider selection: "gemini" (default), "openai" or "ollama".
+	// The openai provider works with any OpenAI-compatible server.
+	// The same provider computes embeddings with EMBED_MODEL.
+	LLM_PROVIDER string
+	LLM_BASE_URL string
+	LLM_API_KEY  string
+	LLM_MODEL    string
+
+	// Number of background document ingestion workers
+	INGEST_WORKERS int
+
+	// Chunker: "structured" (default) or "fixed", sizes in estimated tokens
+	CHUNK_STRATEGY       string
+	CHUNK_MAX_TOKENS     int
+	CHUNK_OVERLAP_TOKENS int
+
+	// Lifetimes of access tokens (JWTs) and of the refresh tokens that renew them
+	ACCESS_TOKEN_TTL  time.Duration
+	REFRESH_TOKEN_TTL time.Duration
+
+	// Outgoing mail: MAILER is "log" (default, prints messages) or "smtp".
+	// APP_URL is the frontend base URL used in emailed links.
+	MAILER        string
+	SMTP_HOST     string
+	SMTP_PORT     int
+	SMTP_USERNAME string
+	SMTP_PASSWORD string
+	MAIL_FROM     string
+	APP_URL       string
+
+	// OpenID Connect sign-in; disabled unless OIDC_ISSUER and OIDC_CLIENT_ID are set.
+	// OIDC_REDIRECT_URL is this server's callback, registered with the provider.
+	OIDC_ISSUER        string
+	OIDC_CLIENT_ID     string
+	OIDC_CLIENT_SECRET string
+	OIDC_REDIRECT_URL  string
+	OIDC_SCOPES        string
 }
 
 var AppConfig Config
@@ -26,6 +66,32 @@ func LoadConfig() {
 		GEMINI_API_KEY: os.Getenv("GEMINI_API_KEY"),
 		GEMINI_MODEL:   os.Getenv("GEMINI_MODEL"),
 		EMBED_MODEL:    os.Getenv("EMBED_MODEL"),
+		LLM_PROVIDER:   getEnv("LLM_PROVIDER", "gemini"),
+		LLM_BASE_URL:   os.Getenv("LLM_BASE_URL"),
+		LLM_API_KEY:    os.Getenv("LLM_API_KEY"),
+		LLM_MODEL:      os.Getenv("LLM_MODEL"),
+		INGEST_WORKERS: getEnvInt("INGEST_WORKERS", 2),
+
+		CHUNK_STRATEGY:       getEnv("CHUNK_STRATEGY", "structured"),
+		CHUNK_MAX_TOKENS:     getEnvInt("CHUNK_MAX_TOKENS", 400),
+		CHUNK_OVERLAP_TOKENS: getEnvInt("CHUNK_OVERLAP_TOKENS", 50),
+
+		ACCESS_TOKEN_TTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		REFRESH_TOKEN_TTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		MAILER:        getEnv("MAILER", "log"),
+		SMTP_HOST:     getEnv("SMTP_HOST", "localhost"),
+		SMTP_PORT:     getEnvInt("SMTP_PORT", 587),
+		SMTP_USERNAME: os.Getenv("SMTP_USERNAME"),
+		SMTP_PASSWORD: os.Getenv("SMTP_PASSWORD"),
+		MAIL_FROM:     getEnv("MAIL_FROM", "SkillUp <no-reply@localhost>"),
+		APP_URL:       getEnv("APP_URL", "http://localhost:3000"),
+
+		OIDC_ISSUER:        os.Getenv("OIDC_ISSUER"),
+		OIDC_CLIENT_ID:     os.Getenv("OIDC_CLIENT_ID"),
+		OIDC_CLIENT_SECRET: os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDC_REDIRECT_URL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:8080/api/auth/oidc/callback"),
+		OIDC_SCOPES:        getEnv("OIDC_SCOPES", "openid email profile"),
 	}
 
 	if AppConfig.DB_URL == "" {
@@ -38,3 +104,39 @@ func LoadConfig() {
 		log.Println("Warning: GEMINI_API_KEY not set — Gemini calls will fail")
 	}
 }
+
+// getEnv returns the environment variable or a fallback when unset
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getEnvInt parses an integer environment variable, using fallback when unset or invalid
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Warning: %s=%q is not a number — using %d", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvDuration parses a duration such as "15m" or "720h", using fallback when unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: %s=%q is not a duration — using %s", key, v, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"skillup-backend/db"
+	"skillup-backend/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMe returns the signed-in user's profile
+func GetMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var identities []db.UserIdentity
+	if err := db.DB.Where("user_id = ?", user.ID).Order("created_at").Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load profile"})
+		return
+	}
+	linked := make([]gin.H, 0, len(identities))
+	for _, identity := range identities {
+		linked = append(linked, gin.H{
+			"issuer":     identity.Issuer,
+			"email":      identity.Email,
+			"created_at": identity.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":             user.ID,
+		"email":          user.Email,
+		"name":           user.Name,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"pending_email":  user.PendingEmail,
+		"has_password":   user.Password != "",
+		"identities":     linked,
+		"created_at":     user.CreatedAt,
+	})
+}
+
+// UpdateMe changes the signed-in user's display name
+func UpdateMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name *string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	name := strings.TrimSpace(*body.Name)
+	if len(name) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is too long"})
+		return
+	}
+
+	if err := db.DB.Model(user).Update("name", name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update profile"})
+		return
+	}
+	user.Name = name
+	c.JSON(http.StatusOK, gin.H{
+		"id":             user.ID,
+		"email":          user.Email,
+		"name":           user.Name,
+		"email_verified": user.EmailVerifiedAt != nil,
+	})
+}
+
+// ChangeEmail starts an email change: the new address gets a confirmation
+// link and replaces the current one once it is opened. Accounts with a
+// password must confirm it.
+func ChangeEmail(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Email    string `json:"email" binding:"required,email"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	if user.Password != "" && !services.CheckPassword(user, body.Password) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrWrongPassword.Error()})
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(body.Email), user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "this is already your email address"})
+		return
+	}
+
+	err := services.RequestEmailChange(user, body.Email)
+	if errors.Is(err, services.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("email change for user %s: %v", user.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send confirmation email"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "sent", "pending_email": user.PendingEmail})
+}
+
+// ChangePassword sets a new password and signs out every other session.
+// Accounts without a password are emailed a link to set their first one
+// instead, since there is no current password to confirm.
+func ChangePassword(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password == "" {
+		err := services.SendPasswordResetEmail(user)
+		if errors.Is(err, services.ErrUserTokenCooldown) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Printf("password setup email for user %s: %v", user.ID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send password setup email"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"status": "sent"})
+		return
+	}
+
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	err := services.ChangePassword(user, c.GetString("session_id"), body.CurrentPassword, body.NewPassword)
+	if errors.Is(err, services.ErrWrongPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// DeleteMe permanently deletes the account with all its documents, quizzes,
+// flashcards, chats and goals. Accounts with a password must confirm it;
+// accounts without one confirm by repeating their email address.
+func DeleteMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Password     string `json:"password"`
+		ConfirmEmail string `json:"confirm_email"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	if user.Password != "" {
+		if !services.CheckPassword(user, body.Password) {
+			c.JSON(http.StatusForbidden, gin.H{"error": services.ErrWrongPassword.Error()})
+			return
+		}
+	} else if !strings.EqualFold(strings.TrimSpace(body.ConfirmEmail), user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm_email does not match your email address"})
+		return
+	}
+
+	err := services.DeleteAccount(user.ID)
+	if errors.Is(err, services.ErrIngestionInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a document is still being processed; try again when it has finished"})
+		return
+	}
+	if err != nil {
+		log.Printf("delete account %s: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
+		return
+	}
+	if err := services.RevokeAccessToken(user.ID, c.GetString("token_id"), c.GetTime("token_expires_at")); err != nil {
+		log.Printf("delete account %s: revoke access token: %v", user.ID, err)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// currentUser loads the signed-in user, responding with 404 if the account
+// no longer exists
+func currentUser(c *gin.Context) (*db.User, bool) {
+	var user db.User
+	if err := db.DB.Where("id = ?", c.GetString("user_id")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	return &user, true
+}
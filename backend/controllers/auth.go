@@ -1,9 +1,15 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"net/url"
+	"skillup-backend/config"
 	"skillup-backend/db"
-	"skillup-backend/utils"
+	"skillup-backend/services"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -49,21 +55,9 @@ func Signup(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT
-	token, err := utils.GenerateJWT(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
-		return
-	}
+	go sendVerificationEmail(user)
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-		},
-	})
+	respondSession(c, &user)
 }
 
 func Login(c *gin.Context) {
@@ -90,20 +84,265 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT
-	token, err := utils.GenerateJWT(user.ID)
+	respondSession(c, &user)
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The old
+// refresh token stops working; presenting it again ends the session.
+func RefreshToken(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	pair, err := services.RefreshSession(body.RefreshToken, c.Request.UserAgent())
+	if errors.Is(err, services.ErrInvalidRefreshToken) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout ends the current session: its refresh tokens are revoked and the
+// access token used for the request is denylisted. With "all": true every
+// session of the user is ended.
+func Logout(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		All bool `json:"all"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+			return
+		}
+	}
+
+	var err error
+	if body.All {
+		err = services.RevokeAllSessions(userId)
+	} else if sessionId := c.GetString("session_id"); sessionId != "" {
+		err = services.RevokeSession(userId, sessionId)
+	}
+	if err == nil {
+		err = services.RevokeAccessToken(userId, c.GetString("token_id"), c.GetTime("token_expires_at"))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondSession starts a session for the user and returns its tokens
+func respondSession(c *gin.Context, user *db.User) {
+	pair, err := services.StartSession(user.ID, c.Request.UserAgent())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
+		"token":         pair.AccessToken,
+		"refresh_token": pair.RefreshToken,
+		"expires_in":    pair.ExpiresIn,
 		"user": gin.H{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
+			"id":             user.ID,
+			"email":          user.Email,
+			"name":           user.Name,
+			"email_verified": user.EmailVerifiedAt != nil,
 		},
 	})
 }
 
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the address has an account, so it cannot be used to probe
+// for registered emails. Asking again within a few minutes sends nothing new.
+func ForgotPassword(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	var user db.User
+	if err := db.DB.Where("email = ?", body.Email).First(&user).Error; err == nil {
+		go func() {
+			err := services.SendPasswordResetEmail(&user)
+			if err != nil && !errors.Is(err, services.ErrUserTokenCooldown) {
+				log.Printf("password reset email for user %s: %v", user.ID, err)
+			}
+		}()
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "if the address has an account, a reset link has been sent"})
+}
+
+// ResetPassword sets a new password with the token from a reset email, signs
+// out every session and deletes the user's personal access tokens
+func ResetPassword(c *gin.Context) {
+	var body struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	err := services.ResetPassword(body.Token, body.Password)
+	if errors.Is(err, services.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// VerifyEmail confirms the user's address with the token from a verification email
+func VerifyEmail(c *gin.Context) {
+	var body struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	err := services.VerifyEmail(body.Token)
+	if errors.Is(err, services.ErrInvalidUserToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ResendVerificationEmail sends the signed-in user a new verification link
+func ResendVerificationEmail(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var user db.User
+	if err := db.DB.Where("id = ?", userId).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "email is already verified"})
+		return
+	}
+
+	err := services.SendVerificationEmail(&user)
+	if errors.Is(err, services.ErrUserTokenCooldown) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("verification email for user %s: %v", user.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send verification email"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "sent"})
+}
+
+func sendVerificationEmail(user db.User) {
+	if err := services.SendVerificationEmail(&user); err != nil {
+		log.Printf("verification email for user %s: %v", user.ID, err)
+	}
+}
+
+// oidcStateCookie ties a pending single sign-on to the browser that started
+// it, so an attacker cannot send a victim to the callback with the attacker's
+// own code and state and sign them into the attacker's account
+const (
+	oidcStateCookie     = "skillup_oidc_state"
+	oidcStateCookiePath = "/api/auth/oidc"
+)
+
+// OIDCLogin redirects the browser to the identity provider to sign in
+func OIDCLogin(c *gin.Context) {
+	authURL, state, err := services.BeginOIDCLogin(c.Request.Context())
+	if errors.Is(err, services.ErrOIDCDisabled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+	// Lax lets the cookie through on the provider's top-level redirect back
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, services.OIDCStateBinding(state), int(services.OIDCStateTTL.Seconds()),
+		oidcStateCookiePath, "", true, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback completes sign-in at the identity provider and sends the
+// browser back to the frontend with SkillUp tokens in the URL fragment,
+// which browsers do not send to servers or in Referer headers
+func OIDCCallback(c *gin.Context) {
+	binding, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, oidcStateCookiePath, "", true, true)
+
+	if errParam := c.Query("error"); errParam != "" {
+		redirectToApp(c, "/login", url.Values{"error": {"sso_" + errParam}})
+		return
+	}
+	if !services.CheckOIDCStateBinding(binding, c.Query("state")) {
+		redirectToApp(c, "/login", url.Values{"error": {"sso_expired"}})
+		return
+	}
+
+	user, err := services.CompleteOIDCLogin(c.Request.Context(), c.Query("state"), c.Query("code"))
+	if err != nil {
+		code := "sso_failed"
+		switch {
+		case errors.Is(err, services.ErrOIDCInvalidState):
+			code = "sso_expired"
+		case errors.Is(err, services.ErrOIDCEmailNotVerified):
+			code = "sso_email_not_verified"
+		case errors.Is(err, services.ErrOIDCAccountNotVerified):
+			code = "sso_account_not_verified"
+		case errors.Is(err, services.ErrOIDCDisabled):
+			code = "sso_disabled"
+		default:
+			log.Printf("OIDC callback: %v", err)
+		}
+		redirectToApp(c, "/login", url.Values{"error": {code}})
+		return
+	}
+
+	pair, err := services.StartSession(user.ID, c.Request.UserAgent())
+	if err != nil {
+		redirectToApp(c, "/login", url.Values{"error": {"sso_failed"}})
+		return
+	}
+	fragment := url.Values{
+		"token":         {pair.AccessToken},
+		"refresh_token": {pair.RefreshToken},
+		"expires_in":    {strconv.Itoa(pair.ExpiresIn)},
+	}
+	c.Redirect(http.StatusFound, strings.TrimRight(config.AppConfig.APP_URL, "/")+"/auth/callback#"+fragment.Encode())
+}
+
+func redirectToApp(c *gin.Context, path string, query url.Values) {
+	c.Redirect(http.StatusFound, strings.TrimRight(config.AppConfig.APP_URL, "/")+path+"?"+query.Encode())
+}
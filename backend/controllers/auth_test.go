@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"skillup-backend/config"
+	"skillup-backend/services"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOIDCCallbackStateCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.AppConfig
+	config.AppConfig.APP_URL = "http://app.test"
+	t.Cleanup(func() { config.AppConfig = prev })
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/callback", OIDCCallback)
+
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{"no cookie", ""},
+		{"cookie for another state", services.OIDCStateBinding("attacker-state")},
+		{"state copied into the cookie", "victim-state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/auth/oidc/callback?state=victim-state&code=attacker-code", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			loc, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusFound || loc.Path != "/login" || loc.Query().Get("error") != "sso_expired" {
+				t.Errorf("got %d to %s, want a redirect to /login with sso_expired", w.Code, loc)
+			}
+			setCookie := w.Header().Get("Set-Cookie")
+			if !strings.HasPrefix(setCookie, oidcStateCookie+"=;") || !strings.Contains(setCookie, "Max-Age=0") {
+				t.Errorf("Set-Cookie = %q, want the state cookie cleared", setCookie)
+			}
+		})
+	}
+}
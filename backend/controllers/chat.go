@@ -1,53 +1,280 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"skillup-backend/db"
 	"skillup-backend/services"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// chatHistoryTurns is how many earlier exchanges are fed back into prompts
+const chatHistoryTurns = 6
+
+// chatRequest is the body accepted by ChatQuery and ChatStream
+type chatRequest struct {
+	services.SearchOptions // top_k, vector_weight, keyword_weight
+	services.ChatScope     // document_ids, collection_id, goal_id
+
+	Query          string  `json:"query"`
+	ConversationID *string `json:"conversation_id"`
+}
+
+// preparedChat holds everything needed to answer one query
+type preparedChat struct {
+	query        string
+	searchQuery  string
+	conversation *db.Conversation
+	history      []services.ChatTurn
+	chunks       []services.RetrievedChunk
+}
+
 func ChatQuery(c *gin.Context) {
+	if c.Query("stream") == "true" {
+		ChatStream(c)
+		return
+	}
+
 	userId := c.GetString("user_id")
-	var body struct {
-		Query string `json:"query"`
+	chat, ok := prepareChat(c, userId)
+	if !ok {
+		return
+	}
+
+	context := services.BuildContextFromChunks(chat.chunks)
+
+	// ask LLM
+	answer, err := services.RAGAnswer(chat.query, context, chat.history)
+	if err != nil {
+		respondLLMError(c, "failed to generate answer", err)
+		return
+	}
+
+	// store chat
+	citations := services.BuildCitations(chat.chunks, answer)
+	saveChatMessage(userId, chat, answer, citations)
+
+	resp := gin.H{
+		"answer":    answer,
+		"citations": citations,
+	}
+	if chat.conversation != nil {
+		resp["conversation_id"] = chat.conversation.ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ChatStream answers a chat query over Server-Sent Events.
+// Events: "sources" (numbered citations), "token" (answer deltas), then "done" or "error".
+func ChatStream(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	// Setup errors are still reported as plain JSON; the stream starts after
+	chat, ok := prepareChat(c, userId)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.SSEvent("sources", services.BuildCitations(chat.chunks, ""))
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	context := services.BuildContextFromChunks(chat.chunks)
+	answer, err := services.RAGAnswerStream(ctx, chat.query, context, chat.history, func(token string) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c.SSEvent("token", gin.H{"text": token})
+		c.Writer.Flush()
+		return nil
+	})
+
+	// Persist whatever was generated, including partial answers when the client went away
+	citations := services.BuildCitations(chat.chunks, answer)
+	var msg *db.ChatMessage
+	if strings.TrimSpace(answer) != "" {
+		msg = saveChatMessage(userId, chat, answer, citations)
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return // client disconnected; nobody to tell
+		}
+		_ = c.Error(err)
+		errMsg := "failed to generate answer"
+		var llmErr *services.LLMError
+		if errors.As(err, &llmErr) {
+			errMsg += ": " + llmErr.Kind.Error()
+		}
+		c.SSEvent("error", gin.H{"error": errMsg, "status": llmErrorStatus(err)})
+		c.Writer.Flush()
+		return
+	}
+
+	done := gin.H{"answer": answer, "citations": citations}
+	if msg != nil {
+		done["message_id"] = msg.ID
 	}
+	if chat.conversation != nil {
+		done["conversation_id"] = chat.conversation.ID
+	}
+	c.SSEvent("done", done)
+	c.Writer.Flush()
+}
+
+// prepareChat validates the request, loads conversation history, rewrites
+// follow-ups into standalone queries and retrieves matching chunks.
+// On failure it writes the error response and returns false.
+func prepareChat(c *gin.Context, userId string) (*preparedChat, bool) {
+	var body chatRequest
 	if err := c.BindJSON(&body); err != nil || strings.TrimSpace(body.Query) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+		return nil, false
+	}
+
+	if err := body.SearchOptions.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	chat := &preparedChat{query: body.Query, searchQuery: body.Query}
+
+	if body.ConversationID != nil && *body.ConversationID != "" {
+		var conv db.Conversation
+		if err := db.DB.Where("id = ? AND user_id = ?", *body.ConversationID, userId).First(&conv).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+			return nil, false
+		}
+		chat.conversation = &conv
+
+		// Most recent turns, then flipped to oldest first for the prompt
+		var recent []db.ChatMessage
+		db.DB.Where("conversation_id = ?", conv.ID).Order("created_at desc").Limit(chatHistoryTurns).Find(&recent)
+		for i := len(recent) - 1; i >= 0; i-- {
+			chat.history = append(chat.history, services.ChatTurn{
+				Question: recent[i].Question,
+				Answer:   recent[i].Answer,
+			})
+		}
+
+		standalone, err := services.RewriteFollowUp(chat.history, body.Query)
+		if err != nil {
+			respondLLMError(c, "failed to interpret follow-up question", err)
+			return nil, false
+		}
+		chat.searchQuery = standalone
+	}
+
+	// An explicit scope wins and is remembered on the conversation;
+	// otherwise follow-ups reuse the conversation's scope
+	scope := body.ChatScope
+	if chat.conversation != nil {
+		if scope.IsEmpty() {
+			scope = services.ScopeFromConversation(chat.conversation)
+		} else {
+			services.ApplyScopeToConversation(chat.conversation, scope)
+		}
+	}
+
+	documentIds, err := services.ResolveScope(userId, scope)
+	if err != nil {
+		respondScopeError(c, err)
+		return nil, false
+	}
+	body.SearchOptions.DocumentIDs = documentIds
+
+	if chat.conversation != nil && !body.ChatScope.IsEmpty() {
+		db.DB.Model(chat.conversation).Select("scope_document_ids", "scope_collection_id", "scope_goal_id").Updates(chat.conversation)
+	}
+
+	chunks, ok := retrieveChunks(c, userId, chat.searchQuery, body.SearchOptions)
+	if !ok {
+		return nil, false
+	}
+	chat.chunks = chunks
+	return chat, true
+}
+
+// respondScopeError writes the response for a failed services.ResolveScope
+func respondScopeError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrScopeNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve chat scope"})
+}
 
+// retrieveChunks embeds the query and runs hybrid search over the user's chunks.
+// On failure it writes the error response and returns false.
+func retrieveChunks(c *gin.Context, userId, query string, opts services.SearchOptions) ([]services.RetrievedChunk, bool) {
 	// embed query
-	qEmb := services.GetEmbedding(body.Query)
-
-	// vector search in document_chunks for this user
-	var chunks []db.DocumentChunk
-	// NOTE: we use raw SQL ordering by distance using pgvector operator <->.
-	// GORM will map the param qEmb; pgvector-go implements driver.Valuer to pass vector.
-	db.DB.Raw(`
-		SELECT * FROM document_chunks
-		WHERE user_id = ?
-		ORDER BY embedding <-> ?
-		LIMIT 5`, userId, qEmb).Scan(&chunks)
+	qEmb, err := services.GetEmbedding(query)
+	if err != nil {
+		respondLLMError(c, "failed to embed query", err)
+		return nil, false
+	}
 
-	context := services.BuildContextFromChunks(chunks)
+	// vector + keyword search in document_chunks for this user
+	chunks, err := services.HybridSearch(userId, query, qEmb, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search documents"})
+		return nil, false
+	}
+	return chunks, true
+}
 
-	// ask LLM
-	answer := services.RAGAnswer(body.Query, context)
+// saveChatMessage stores a question/answer pair; failures are logged, not surfaced
+func saveChatMessage(userId string, chat *preparedChat, answer string, citations []services.Citation) *db.ChatMessage {
+	sourcesJSON, err := json.Marshal(citations)
+	if err != nil {
+		sourcesJSON = []byte("[]")
+	}
 
-	// store chat
 	msg := db.ChatMessage{
 		ID:       uuid.NewString(),
 		UserID:   userId,
-		Question: body.Query,
+		Question: chat.query,
 		Answer:   answer,
+		Sources:  sourcesJSON,
+	}
+	if chat.searchQuery != chat.query {
+		msg.SearchQuery = chat.searchQuery
+	}
+	if chat.conversation != nil {
+		msg.ConversationID = &chat.conversation.ID
+	}
+	if err := db.DB.Create(&msg).Error; err != nil {
+		log.Println("failed to save chat message:", err)
+		return nil
 	}
-	db.DB.Create(&msg)
 
-	c.JSON(http.StatusOK, gin.H{
-		"answer": answer,
-	})
+	if chat.conversation != nil {
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if chat.conversation.Title == "" {
+			updates["title"] = conversationTitle(chat.query)
+		}
+		db.DB.Model(chat.conversation).Updates(updates)
+	}
+	return &msg
+}
+
+// conversationTitle derives a default title from the first question
+func conversationTitle(question string) string {
+	title := []rune(strings.TrimSpace(question))
+	if len(title) > 60 {
+		title = append(title[:60], '…')
+	}
+	return string(title)
 }
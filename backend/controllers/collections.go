@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"net/http"
+	"skillup-backend/db"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetCollections(c *gin.Context) {
+	userId := c.GetString("user_id")
+	var collections []db.Collection
+	db.DB.Where("user_id = ?", userId).Order("created_at desc").Find(&collections)
+	c.JSON(http.StatusOK, collections)
+}
+
+func CreateCollection(c *gin.Context) {
+	userId := c.GetString("user_id")
+	var body struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		DocumentIDs []string `json:"document_ids"`
+	}
+	if err := c.BindJSON(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	owned, err := ownsDocuments(userId, body.DocumentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check documents"})
+		return
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	col := db.Collection{
+		ID:          uuid.NewString(),
+		UserID:      userId,
+		Name:        strings.TrimSpace(body.Name),
+		Description: body.Description,
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&col).Error; err != nil {
+			return err
+		}
+		return addCollectionDocuments(tx, col.ID, body.DocumentIDs)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, col)
+}
+
+// GetCollection returns a collection with its documents
+func GetCollection(c *gin.Context) {
+	userId := c.GetString("user_id")
+	collectionId := c.Param("collection_id")
+
+	var col db.Collection
+	if err := db.DB.Where("id = ? AND user_id = ?", collectionId, userId).First(&col).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+
+	var docs []db.Document
+	db.DB.Where("user_id = ? AND id IN (?)", userId,
+		db.DB.Model(&db.CollectionDocument{}).Select("document_id").Where("collection_id = ?", col.ID)).
+		Order("upload_date desc").Find(&docs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": col,
+		"documents":  docs,
+	})
+}
+
+func DeleteCollection(c *gin.Context) {
+	userId := c.GetString("user_id")
+	collectionId := c.Param("collection_id")
+
+	var col db.Collection
+	if err := db.DB.Where("id = ? AND user_id = ?", collectionId, userId).First(&col).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+
+	// Documents themselves are kept; only the grouping goes away
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", col.ID).Delete(&db.CollectionDocument{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&db.Conversation{}).Where("scope_collection_id = ?", col.ID).Update("scope_collection_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&col).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "collection_id": col.ID})
+}
+
+// AddCollectionDocuments adds documents (body: document_ids) to a collection
+func AddCollectionDocuments(c *gin.Context) {
+	userId := c.GetString("user_id")
+	collectionId := c.Param("collection_id")
+
+	var body struct {
+		DocumentIDs []string `json:"document_ids"`
+	}
+	if err := c.BindJSON(&body); err != nil || len(body.DocumentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document_ids is required"})
+		return
+	}
+
+	var col db.Collection
+	if err := db.DB.Where("id = ? AND user_id = ?", collectionId, userId).First(&col).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+	owned, err := ownsDocuments(userId, body.DocumentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check documents"})
+		return
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	if err := addCollectionDocuments(db.DB, col.ID, body.DocumentIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add documents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "collection_id": col.ID})
+}
+
+func RemoveCollectionDocument(c *gin.Context) {
+	userId := c.GetString("user_id")
+	collectionId := c.Param("collection_id")
+	documentId := c.Param("document_id")
+
+	var col db.Collection
+	if err := db.DB.Where("id = ? AND user_id = ?", collectionId, userId).First(&col).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "collection not found"})
+		return
+	}
+
+	if err := db.DB.Where("collection_id = ? AND document_id = ?", col.ID, documentId).
+		Delete(&db.CollectionDocument{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "collection_id": col.ID})
+}
+
+func addCollectionDocuments(tx *gorm.DB, collectionId string, documentIds []string) error {
+	if len(documentIds) == 0 {
+		return nil
+	}
+	links := make([]db.CollectionDocument, 0, len(documentIds))
+	for _, id := range documentIds {
+		links = append(links, db.CollectionDocument{CollectionID: collectionId, DocumentID: id})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// ownsDocuments reports whether every ID names a document of the user
+func ownsDocuments(userId string, documentIds []string) (bool, error) {
+	if len(documentIds) == 0 {
+		return true, nil
+	}
+	unique := map[string]bool{}
+	for _, id := range documentIds {
+		unique[id] = true
+	}
+	var count int64
+	if err := db.DB.Model(&db.Document{}).Where("user_id = ? AND id IN ?", userId, documentIds).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return int(count) == len(unique), nil
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"net/http"
+	"skillup-backend/db"
+	"skillup-backend/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateConversation starts a new chat thread, optionally scoped to
+// documents, a collection or a goal
+func CreateConversation(c *gin.Context) {
+	userId := c.GetString("user_id")
+	var body struct {
+		Title string `json:"title"`
+		services.ChatScope
+	}
+	// Body is optional; the title is set from the first question otherwise
+	_ = c.ShouldBindJSON(&body)
+
+	if _, err := services.ResolveScope(userId, body.ChatScope); err != nil {
+		respondScopeError(c, err)
+		return
+	}
+
+	conv := db.Conversation{
+		ID:     uuid.NewString(),
+		UserID: userId,
+		Title:  strings.TrimSpace(body.Title),
+	}
+	services.ApplyScopeToConversation(&conv, body.ChatScope)
+	if err := db.DB.Create(&conv).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, conv)
+}
+
+// GetConversations lists the user's conversations, most recently active first
+func GetConversations(c *gin.Context) {
+	userId := c.GetString("user_id")
+	var convs []db.Conversation
+	db.DB.Where("user_id = ?", userId).Order("updated_at desc").Limit(100).Find(&convs)
+	c.JSON(http.StatusOK, convs)
+}
+
+// UpdateConversation renames a conversation and/or replaces its scope.
+// Send "scope": {} to search every document again.
+func UpdateConversation(c *gin.Context) {
+	userId := c.GetString("user_id")
+	conversationId := c.Param("conversation_id")
+
+	var body struct {
+		Title *string             `json:"title"`
+		Scope *services.ChatScope `json:"scope"`
+	}
+	if err := c.BindJSON(&body); err != nil || (body.Title == nil && body.Scope == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title or scope is required"})
+		return
+	}
+	if body.Title != nil && strings.TrimSpace(*body.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title must not be empty"})
+		return
+	}
+
+	var conv db.Conversation
+	if err := db.DB.Where("id = ? AND user_id = ?", conversationId, userId).First(&conv).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+
+	if body.Title != nil {
+		conv.Title = strings.TrimSpace(*body.Title)
+	}
+	if body.Scope != nil {
+		if _, err := services.ResolveScope(userId, *body.Scope); err != nil {
+			respondScopeError(c, err)
+			return
+		}
+		services.ApplyScopeToConversation(&conv, *body.Scope)
+	}
+
+	if err := db.DB.Save(&conv).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, conv)
+}
+
+// DeleteConversation removes a conversation and its messages
+func DeleteConversation(c *gin.Context) {
+	userId := c.GetString("user_id")
+	conversationId := c.Param("conversation_id")
+
+	var conv db.Conversation
+	if err := db.DB.Where("id = ? AND user_id = ?", conversationId, userId).First(&conv).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", conv.ID).Delete(&db.ChatMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&conv).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete conversation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "conversation_id": conv.ID})
+}
+
+// GetConversationMessages returns a conversation's messages, oldest first
+func GetConversationMessages(c *gin.Context) {
+	userId := c.GetString("user_id")
+	conversationId := c.Param("conversation_id")
+
+	var conv db.Conversation
+	if err := db.DB.Where("id = ? AND user_id = ?", conversationId, userId).First(&conv).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "conversation not found"})
+		return
+	}
+
+	var msgs []db.ChatMessage
+	db.DB.Where("conversation_id = ?", conv.ID).Order("created_at asc").Find(&msgs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation": conv,
+		"messages":     msgs,
+	})
+}
@@ -1,13 +1,20 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
 	"skillup-backend/db"
 	"skillup-backend/services"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
 )
 
 // UploadDocument accepts multipart form "file"
@@ -27,43 +34,79 @@ func UploadDocument(c *gin.Context) {
 		return
 	}
 
-	doc := db.Document{
-		ID:       uuid.NewString(),
-		UserID:   userId,
-		Filename: header.Filename,
+	mimeType := services.DetectMimeType(data, header.Filename)
+	if mimeType == "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported file format; upload a PDF, DOCX, EPUB, Markdown, HTML, text or SRT/VTT subtitle file"})
+		return
+	}
+
+	// Uploading a file again returns the existing document unless an alias
+	// (a separate document sharing the already processed content) is asked for
+	duplicate := c.DefaultPostForm("duplicate", "existing")
+	if duplicate != "existing" && duplicate != "alias" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duplicate must be existing or alias"})
+		return
+	}
+	hash := services.ContentHash(data)
+	existing, err := services.FindDuplicateDocument(userId, hash)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check for duplicates"})
+		return
+	}
+	if existing != nil && duplicate == "existing" {
+		c.JSON(http.StatusOK, gin.H{
+			"status":            "duplicate",
+			"document_id":       existing.ID,
+			"processing_status": existing.ProcessingStatus,
+			"duplicate":         true,
+		})
+		return
 	}
-	db.DB.Create(&doc)
 
-	// Extract text synchronously (MVP)
-	text := services.PDFToText(data)
+	doc := db.Document{
+		ID:               uuid.NewString(),
+		UserID:           userId,
+		Filename:         header.Filename,
+		MimeType:         mimeType,
+		ContentHash:      hash,
+		ProcessingStatus: "uploaded",
+	}
+	if existing != nil {
+		doc.DuplicateOf = &existing.ID
+	}
+	if err := db.DB.Create(&doc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save document"})
+		return
+	}
 
+	// Text is filled in by the ingestion worker
 	raw := db.DocumentRaw{
-		ID:         uuid.NewString(),
-		DocumentID: doc.ID,
-		UserID:     userId,
-		Text:       text,
-		FileData:   data, // Store original PDF
-	}
-	db.DB.Create(&raw)
-
-	// Chunk + embed + store
-	chunks := services.ChunkText(text)
-	for _, ch := range chunks {
-		emb := services.GetEmbedding(ch)
-		db.DB.Create(&db.DocumentChunk{
-			ID:         uuid.NewString(),
-			DocumentID: doc.ID,
-			UserID:     userId,
-			ChunkText:  ch,
-			Embedding:  emb,
-		})
+		ID:          uuid.NewString(),
+		DocumentID:  doc.ID,
+		UserID:      userId,
+		Text:        "",
+		FileData:    data, // Store original file
+		ContentHash: hash,
+	}
+	if err := db.DB.Create(&raw).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save document file"})
+		return
 	}
 
-	// mark processed
-	doc.ProcessingStatus = "processed"
-	db.DB.Save(&doc)
+	// Extraction, chunking and embedding run in the background
+	job, err := services.EnqueueIngestion(doc.ID, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue document for processing"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok", "document_id": doc.ID})
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":            "ok",
+		"document_id":       doc.ID,
+		"job_id":            job.ID,
+		"processing_status": doc.ProcessingStatus,
+		"duplicate_of":      doc.DuplicateOf,
+	})
 }
 
 func GetDocuments(c *gin.Context) {
@@ -87,6 +130,186 @@ func GetDocument(c *gin.Context) {
 	c.JSON(http.StatusOK, doc)
 }
 
+// UpdateDocument renames a document (body: filename and/or title)
+func UpdateDocument(c *gin.Context) {
+	userId := c.GetString("user_id")
+	documentId := c.Param("document_id")
+
+	var body struct {
+		Filename *string `json:"filename"`
+		Title    *string `json:"title"`
+	}
+	if err := c.BindJSON(&body); err != nil || (body.Filename == nil && body.Title == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename or title is required"})
+		return
+	}
+	if body.Filename != nil && (strings.TrimSpace(*body.Filename) == "" || len(*body.Filename) > 255) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename must be 1-255 characters"})
+		return
+	}
+	if body.Title != nil && len(*body.Title) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title must be at most 500 characters"})
+		return
+	}
+
+	var doc db.Document
+	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	if body.Filename != nil {
+		doc.Filename = strings.TrimSpace(*body.Filename)
+	}
+	if body.Title != nil {
+		doc.Title = strings.TrimSpace(*body.Title)
+	}
+	if err := db.DB.Model(&doc).Select("filename", "title").Updates(&doc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// DeleteDocument removes a document with its file, text, chunks, quizzes and links
+func DeleteDocument(c *gin.Context) {
+	userId := c.GetString("user_id")
+	documentId := c.Param("document_id")
+
+	var doc db.Document
+	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	// A running worker would keep writing chunks for the deleted document;
+	// queued jobs are locked so none is claimed before they are deleted
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := services.LockIngestionJobs(tx, "document_id = ?", doc.ID); err != nil {
+			return err
+		}
+		return db.DeleteDocumentCascade(tx, doc.ID)
+	})
+	if errors.Is(err, services.ErrIngestionInProgress) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete document"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "document_id": doc.ID})
+}
+
+// ReprocessDocument re-extracts, re-chunks and re-embeds a document with the
+// current chunker settings and embedding model
+func ReprocessDocument(c *gin.Context) {
+	userId := c.GetString("user_id")
+	documentId := c.Param("document_id")
+
+	var doc db.Document
+	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	job, err := services.ReprocessDocument(doc.ID, userId)
+	switch {
+	case errors.Is(err, services.ErrIngestionInProgress):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrNoSourceFile):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue document for processing"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":            "ok",
+		"document_id":       doc.ID,
+		"job_id":            job.ID,
+		"processing_status": "uploaded",
+	})
+}
+
+// GetDocumentStatus reports ingestion stage and progress for a document
+func GetDocumentStatus(c *gin.Context) {
+	userId := c.GetString("user_id")
+	documentId := c.Param("document_id")
+
+	var doc db.Document
+	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	resp := gin.H{
+		"document_id":       doc.ID,
+		"processing_status": doc.ProcessingStatus,
+	}
+
+	job, err := services.LatestIngestionJob(doc.ID)
+	if err != nil {
+		// Documents ingested before background jobs existed have no job row
+		if doc.ProcessingStatus == "processed" {
+			resp["stage"] = services.StageDone
+			resp["progress"] = 100
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	resp["job_id"] = job.ID
+	resp["job_status"] = job.Status
+	resp["stage"] = job.Stage
+	resp["progress"] = job.Progress
+	resp["chunks_total"] = job.ChunksTotal
+	resp["chunks_done"] = job.ChunksDone
+	resp["attempts"] = job.Attempts
+	resp["updated_at"] = job.UpdatedAt
+	if job.LastError != "" {
+		resp["last_error"] = job.LastError
+	}
+	if job.Status == services.JobQueued && job.Attempts > 0 {
+		resp["next_retry_at"] = job.NextRunAt
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetDocumentPages returns extracted page text, optionally limited to ?from=&to= (1-based, inclusive)
+func GetDocumentPages(c *gin.Context) {
+	userId := c.GetString("user_id")
+	documentId := c.Param("document_id")
+
+	var doc db.Document
+	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	query := db.DB.Where("document_id = ?", doc.ID)
+	if from, err := strconv.Atoi(c.Query("from")); err == nil {
+		query = query.Where("page_number >= ?", from)
+	}
+	if to, err := strconv.Atoi(c.Query("to")); err == nil {
+		query = query.Where("page_number <= ?", to)
+	}
+
+	var pages []db.DocumentPage
+	query.Order("page_number").Find(&pages)
+
+	c.JSON(http.StatusOK, gin.H{
+		"document_id": doc.ID,
+		"page_count":  doc.PageCount,
+		"pages":       pages,
+	})
+}
+
 // SummarizeDocument generates a summary for a document
 func SummarizeDocument(c *gin.Context) {
 	userId := c.GetString("user_id")
@@ -115,23 +338,25 @@ func SummarizeDocument(c *gin.Context) {
 		return
 	}
 
-	// Fetch document text
-	var docRaw db.DocumentRaw
-	if err := db.DB.Where("document_id = ?", documentId).First(&docRaw).Error; err != nil {
+	// Fetch document text, split into page-ranged sections
+	sections, err := services.DocumentSections(documentId)
+	if err != nil || len(sections) == 0 {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "document text not found"})
 		return
 	}
 
 	// Generate summary using LLM
-	summary, err := services.SummarizeDocument(docRaw.Text, options)
+	result, err := services.SummarizeDocument(sections, options)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate summary: " + err.Error()})
+		respondLLMError(c, "failed to generate summary", err)
 		return
 	}
 
 	// Update document with summary
 	now := time.Now()
-	doc.Summary = summary
+	points, _ := json.Marshal(result.Points)
+	doc.Summary = result.Summary
+	doc.SummaryPoints = datatypes.JSON(points)
 	doc.SummaryGeneratedAt = &now
 
 	if err := db.DB.Save(&doc).Error; err != nil {
@@ -140,13 +365,16 @@ func SummarizeDocument(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"summary":     summary,
-		"document_id": documentId,
+		"summary":      result.Summary,
+		"points":       result.Points,
+		"sections":     result.Sections,
+		"document_id":  documentId,
 		"generated_at": now,
 	})
 }
 
-// GetDocumentFile serves the original PDF file
+// GetDocumentFile serves the original file with its detected content type.
+// Only PDFs and plain text are shown inline, in a sandbox.
 func GetDocumentFile(c *gin.Context) {
 	userId := c.GetString("user_id")
 	documentId := c.Param("document_id")
@@ -170,6 +398,25 @@ func GetDocumentFile(c *gin.Context) {
 		return
 	}
 
-	// Serve the PDF file
-	c.Data(http.StatusOK, "application/pdf", docRaw.FileData)
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = services.MimePDF
+	}
+	// Uploads are user content: HTML or SVG served inline from our origin
+	// could run scripts, so only types the browser renders safely are inline
+	disposition := "attachment"
+	if inlineFileTypes[mimeType] {
+		disposition = "inline"
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": doc.Filename}))
+	c.Data(http.StatusOK, mimeType, docRaw.FileData)
+}
+
+// inlineFileTypes are the original file types GetDocumentFile lets the
+// browser display; everything else is downloaded
+var inlineFileTypes = map[string]bool{
+	services.MimePDF:  true,
+	services.MimeText: true,
 }
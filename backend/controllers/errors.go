@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"skillup-backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// llmErrorStatus maps an error from an LLM or embedding call to an HTTP status
+func llmErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrLLMRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrLLMTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, services.ErrLLMBlocked):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrLLMUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrLLMAuth), errors.Is(err, services.ErrLLMBadResponse):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondLLMError writes a JSON error for a failed LLM or embedding call.
+// Provider details stay in the logs; clients only see the error kind, and
+// nothing at all of other errors (e.g. database failures along the way).
+func respondLLMError(c *gin.Context, msg string, err error) {
+	_ = c.Error(err)
+
+	if kind := services.LLMErrorKind(err); kind != "" {
+		msg += ": " + kind
+	}
+	c.JSON(llmErrorStatus(err), gin.H{"error": msg})
+}
@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"skillup-backend/db"
+	"skillup-backend/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func GetFlashcardDecks(c *gin.Context) {
+	userId := c.GetString("user_id")
+	var decks []db.FlashcardDeck
+	db.DB.Where("user_id = ?", userId).Order("created_at desc").Find(&decks)
+	c.JSON(http.StatusOK, decks)
+}
+
+// CreateFlashcardDeck creates a deck, optionally for a document or goal
+func CreateFlashcardDeck(c *gin.Context) {
+	userId := c.GetString("user_id")
+	var body struct {
+		Name       string  `json:"name"`
+		DocumentID *string `json:"document_id"`
+		GoalID     *string `json:"goal_id"`
+	}
+	if err := c.BindJSON(&body); err != nil || strings.TrimSpace(body.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	if body.DocumentID != nil {
+		owned, err := ownsDocuments(userId, []string{*body.DocumentID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check documents"})
+			return
+		}
+		if !owned {
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+			return
+		}
+	}
+	if body.GoalID != nil {
+		if err := db.DB.Where("id = ? AND user_id = ?", *body.GoalID, userId).First(&db.Goal{}).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+			return
+		}
+	}
+
+	deck := db.FlashcardDeck{
+		ID:         uuid.NewString(),
+		UserID:     userId,
+		Name:       strings.TrimSpace(body.Name),
+		DocumentID: body.DocumentID,
+		GoalID:     body.GoalID,
+	}
+	if err := db.DB.Create(&deck).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create deck"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deck)
+}
+
+// GetFlashcardDeck returns a deck with its cards
+func GetFlashcardDeck(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var deck db.FlashcardDeck
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("deck_id"), userId).First(&deck).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+		return
+	}
+
+	var cards []db.Flashcard
+	db.DB.Where("deck_id = ?", deck.ID).Order("created_at asc").Find(&cards)
+
+	c.JSON(http.StatusOK, gin.H{
+		"deck":  deck,
+		"cards": cards,
+	})
+}
+
+// DeleteFlashcardDeck removes a deck with its cards and review history
+func DeleteFlashcardDeck(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var deck db.FlashcardDeck
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("deck_id"), userId).First(&deck).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		cards := tx.Model(&db.Flashcard{}).Select("id").Where("deck_id = ?", deck.ID)
+		if err := tx.Where("flashcard_id IN (?)", cards).Delete(&db.FlashcardReview{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id = ?", deck.ID).Delete(&db.Flashcard{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&deck).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete deck"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "deck_id": deck.ID})
+}
+
+// GenerateFlashcards adds LLM-written cards to a deck from the deck's document
+// (or body document_id), sampled across the document like quizzes
+func GenerateFlashcards(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Count      int      `json:"count"`
+		DocumentID string   `json:"document_id"`
+		PageFrom   int      `json:"page_from"`
+		PageTo     int      `json:"page_to"`
+		Topics     []string `json:"topics"`
+	}
+	// Body is optional
+	_ = c.ShouldBindJSON(&body)
+
+	var deck db.FlashcardDeck
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("deck_id"), userId).First(&deck).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+		return
+	}
+
+	documentId := body.DocumentID
+	if documentId == "" && deck.DocumentID != nil {
+		documentId = *deck.DocumentID
+	}
+	if documentId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document_id is required for decks without a document"})
+		return
+	}
+
+	var doc db.Document
+	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if doc.ProcessingStatus != "processed" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document is not yet processed"})
+		return
+	}
+
+	if body.Count <= 0 {
+		body.Count = 10
+	}
+	chunks, err := services.SelectQuizChunks(userId, documentId, services.QuizConfig{
+		NumQuestions: body.Count,
+		PageFrom:     body.PageFrom,
+		PageTo:       body.PageTo,
+		Topics:       body.Topics,
+	}, nil)
+	if errors.Is(err, services.ErrNoQuizMaterial) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondLLMError(c, "failed to select flashcard material", err)
+		return
+	}
+
+	var existing []string
+	db.DB.Model(&db.Flashcard{}).Where("deck_id = ?", deck.ID).Pluck("front", &existing)
+
+	generated, err := services.GenerateFlashcards(chunks, existing, body.Count)
+	if err != nil {
+		respondLLMError(c, "failed to generate flashcards", err)
+		return
+	}
+
+	now := time.Now()
+	cards := make([]db.Flashcard, 0, len(generated))
+	for _, g := range generated {
+		card := db.Flashcard{
+			ID:         uuid.NewString(),
+			UserID:     userId,
+			DeckID:     deck.ID,
+			Front:      g.Front,
+			Back:       g.Back,
+			EaseFactor: 2.5,
+			DueAt:      now,
+		}
+		if g.Source != nil {
+			card.SourceChunkID = &g.Source.ChunkID
+			card.PageStart = g.Source.PageStart
+			card.PageEnd = g.Source.PageEnd
+			card.StartMs = g.Source.StartMs
+			card.EndMs = g.Source.EndMs
+		}
+		cards = append(cards, card)
+	}
+	if err := db.DB.Create(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save flashcards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deck_id": deck.ID,
+		"cards":   cards,
+	})
+}
+
+// CreateFlashcard adds a hand-written card to a deck
+func CreateFlashcard(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Front string `json:"front"`
+		Back  string `json:"back"`
+	}
+	if err := c.BindJSON(&body); err != nil || strings.TrimSpace(body.Front) == "" || strings.TrimSpace(body.Back) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "front and back are required"})
+		return
+	}
+
+	var deck db.FlashcardDeck
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("deck_id"), userId).First(&deck).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deck not found"})
+		return
+	}
+
+	card := db.Flashcard{
+		ID:         uuid.NewString(),
+		UserID:     userId,
+		DeckID:     deck.ID,
+		Front:      strings.TrimSpace(body.Front),
+		Back:       strings.TrimSpace(body.Back),
+		EaseFactor: 2.5,
+		DueAt:      time.Now(),
+	}
+	if err := db.DB.Create(&card).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create flashcard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+// UpdateFlashcard edits the front and/or back of a card; its schedule is kept
+func UpdateFlashcard(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Front *string `json:"front"`
+		Back  *string `json:"back"`
+	}
+	if err := c.BindJSON(&body); err != nil || (body.Front == nil && body.Back == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "front or back is required"})
+		return
+	}
+	if (body.Front != nil && strings.TrimSpace(*body.Front) == "") || (body.Back != nil && strings.TrimSpace(*body.Back) == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "front and back must not be empty"})
+		return
+	}
+
+	var card db.Flashcard
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("flashcard_id"), userId).First(&card).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "flashcard not found"})
+		return
+	}
+
+	if body.Front != nil {
+		card.Front = strings.TrimSpace(*body.Front)
+	}
+	if body.Back != nil {
+		card.Back = strings.TrimSpace(*body.Back)
+	}
+	if err := db.DB.Save(&card).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update flashcard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
+
+func DeleteFlashcard(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var card db.Flashcard
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("flashcard_id"), userId).First(&card).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "flashcard not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("flashcard_id = ?", card.ID).Delete(&db.FlashcardReview{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&card).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete flashcard"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "flashcard_id": card.ID})
+}
+
+// GetDueFlashcards returns cards due for review, most overdue first.
+// Optional query params: deck_id, limit (default 50).
+func GetDueFlashcards(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	query := db.DB.Where("user_id = ? AND due_at <= ?", userId, time.Now())
+	if deckId := c.Query("deck_id"); deckId != "" {
+		query = query.Where("deck_id = ?", deckId)
+	}
+
+	var cards []db.Flashcard
+	query.Order("due_at asc").Limit(limit).Find(&cards)
+
+	c.JSON(http.StatusOK, cards)
+}
+
+// ReviewFlashcard records a 0-5 grade and reschedules the card with SM-2
+func ReviewFlashcard(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Grade *int `json:"grade"`
+	}
+	if err := c.BindJSON(&body); err != nil || body.Grade == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grade is required"})
+		return
+	}
+
+	var card db.Flashcard
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("flashcard_id"), userId).First(&card).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "flashcard not found"})
+		return
+	}
+
+	if err := services.ScheduleReview(&card, *body.Grade, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&card).Error; err != nil {
+			return err
+		}
+		return tx.Create(&db.FlashcardReview{
+			ID:           uuid.NewString(),
+			UserID:       userId,
+			FlashcardID:  card.ID,
+			Grade:        *body.Grade,
+			EaseFactor:   card.EaseFactor,
+			IntervalDays: card.IntervalDays,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save review"})
+		return
+	}
+
+	c.JSON(http.StatusOK, card)
+}
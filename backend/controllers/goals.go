@@ -1,44 +1,472 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"skillup-backend/db"
+	"skillup-backend/services"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// GetGoals lists the user's goals; ?status= filters by status
 func GetGoals(c *gin.Context) {
 	userId := c.GetString("user_id")
+	query := db.DB.Where("user_id = ?", userId)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
 	var goals []db.Goal
-	db.DB.Where("user_id = ?", userId).Find(&goals)
+	query.Find(&goals)
 	c.JSON(http.StatusOK, goals)
 }
 
 func CreateGoal(c *gin.Context) {
 	userId := c.GetString("user_id")
 	var body struct {
-		Title      string     `json:"title"`
-		TargetDate *time.Time `json:"target_date"`
-		Status     string     `json:"status"`
+		Title       string     `json:"title"`
+		TargetDate  *time.Time `json:"target_date"`
+		Status      string     `json:"status"`
+		DocumentIDs []string   `json:"document_ids"`
 	}
 	if err := c.BindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
+	if strings.TrimSpace(body.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	if body.Status == "" {
+		body.Status = services.GoalActive
+	}
+	if body.Status != services.GoalActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new goals must be active"})
+		return
+	}
+	owned, err := ownsDocuments(userId, body.DocumentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check documents"})
+		return
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
 	goal := db.Goal{
 		ID:         uuid.NewString(),
 		UserID:     userId,
-		Title:      body.Title,
+		Title:      strings.TrimSpace(body.Title),
 		TargetDate: body.TargetDate,
 		Status:     body.Status,
 	}
-	
-	if err := db.DB.Create(&goal).Error; err != nil {
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&goal).Error; err != nil {
+			return err
+		}
+		return addGoalDocuments(tx, goal.ID, body.DocumentIDs)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create goal"})
 		return
 	}
-	
+
 	c.JSON(http.StatusOK, goal)
 }
+
+// GetGoal returns a goal with its documents and computed progress
+func GetGoal(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	respondGoal(c, &goal)
+}
+
+// UpdateGoal edits title and target date and moves the goal between
+// statuses: active -> completed/archived, completed -> active/archived,
+// archived -> active
+func UpdateGoal(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Title      *string    `json:"title"`
+		TargetDate *time.Time `json:"target_date"`
+		Status     *string    `json:"status"`
+	}
+	if err := c.BindJSON(&body); err != nil || (body.Title == nil && body.TargetDate == nil && body.Status == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title, target_date or status is required"})
+		return
+	}
+	if body.Title != nil && strings.TrimSpace(*body.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title must not be empty"})
+		return
+	}
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	if body.Status != nil {
+		if err := services.ValidateGoalTransition(goal.Status, *body.Status); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		goal.Status = *body.Status
+	}
+	if body.Title != nil {
+		goal.Title = strings.TrimSpace(*body.Title)
+	}
+	if body.TargetDate != nil {
+		goal.TargetDate = body.TargetDate
+	}
+
+	if err := db.DB.Save(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update goal"})
+		return
+	}
+
+	respondGoal(c, &goal)
+}
+
+// DeleteGoal removes a goal and its document links. Topics, decks and
+// conversations that referred to it are kept but detached.
+func DeleteGoal(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("goal_id = ?", goal.ID).Delete(&db.GoalDocument{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&db.Topic{}).Where("goal_id = ?", goal.ID).Update("goal_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&db.FlashcardDeck{}).Where("goal_id = ?", goal.ID).Update("goal_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&db.Conversation{}).Where("scope_goal_id = ?", goal.ID).Update("scope_goal_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&goal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "goal_id": goal.ID})
+}
+
+// AddGoalDocuments links documents (body: document_ids) to a goal
+func AddGoalDocuments(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		DocumentIDs []string `json:"document_ids"`
+	}
+	if err := c.BindJSON(&body); err != nil || len(body.DocumentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "document_ids is required"})
+		return
+	}
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+	owned, err := ownsDocuments(userId, body.DocumentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check documents"})
+		return
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+
+	if err := addGoalDocuments(db.DB, goal.ID, body.DocumentIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link documents"})
+		return
+	}
+
+	respondGoal(c, &goal)
+}
+
+func RemoveGoalDocument(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	if err := db.DB.Where("goal_id = ? AND document_id = ?", goal.ID, c.Param("document_id")).
+		Delete(&db.GoalDocument{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink document"})
+		return
+	}
+
+	respondGoal(c, &goal)
+}
+
+// respondGoal writes a goal with its linked documents and progress
+func respondGoal(c *gin.Context, goal *db.Goal) {
+	var docs []db.Document
+	db.DB.Where("user_id = ? AND id IN (?)", goal.UserID,
+		db.DB.Model(&db.GoalDocument{}).Select("document_id").Where("goal_id = ?", goal.ID)).
+		Order("upload_date desc").Find(&docs)
+
+	progress, err := services.ComputeGoalProgress(goal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal":      goal,
+		"documents": docs,
+		"progress":  progress,
+	})
+}
+
+func addGoalDocuments(tx *gorm.DB, goalId string, documentIds []string) error {
+	if len(documentIds) == 0 {
+		return nil
+	}
+	links := make([]db.GoalDocument, 0, len(documentIds))
+	for _, id := range documentIds {
+		links = append(links, db.GoalDocument{GoalID: goalId, DocumentID: id})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// GetGoalPlan returns the goal's study plan and whether the user is behind on it
+func GetGoalPlan(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+	if goal.AIPlan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal has no plan yet"})
+		return
+	}
+
+	var plan services.StudyPlan
+	if err := json.Unmarshal([]byte(*goal.AIPlan), &plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal_id":      goal.ID,
+		"plan":         plan,
+		"behind":       plan.Behind(time.Now()),
+		"generated_at": goal.PlanGeneratedAt,
+	})
+}
+
+// GenerateGoalPlan builds (or rebuilds) a day-by-day study plan for a goal from
+// its linked documents, target date and the user's weekly availability.
+// Regenerating keeps the pages already marked as read and reschedules the rest
+// from today, which is how a user who fell behind catches up.
+func GenerateGoalPlan(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Availability map[string]int `json:"availability"` // minutes per weekday
+		DocumentIDs  []string       `json:"document_ids"` // linked to the goal before planning
+	}
+	// Body is optional
+	_ = c.ShouldBindJSON(&body)
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+	if goal.Status != services.GoalActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only active goals can be planned"})
+		return
+	}
+	if goal.TargetDate == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "goal needs a target_date to plan"})
+		return
+	}
+
+	// Carry progress (and availability, unless replaced) over from the previous plan
+	var previous services.StudyPlan
+	hasPrevious := goal.AIPlan != nil && json.Unmarshal([]byte(*goal.AIPlan), &previous) == nil
+	availability := body.Availability
+	if len(availability) == 0 && hasPrevious {
+		availability = previous.Availability
+	}
+	availability, err := services.ParseAvailability(availability)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	owned, err := ownsDocuments(userId, body.DocumentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check documents"})
+		return
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err := addGoalDocuments(db.DB, goal.ID, body.DocumentIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link documents"})
+		return
+	}
+
+	var docs []db.Document
+	db.DB.Where("user_id = ? AND id IN (?)", userId,
+		db.DB.Model(&db.GoalDocument{}).Select("document_id").Where("goal_id = ?", goal.ID)).
+		Order("upload_date asc").Find(&docs)
+	if len(docs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "link documents to the goal before planning"})
+		return
+	}
+
+	planDocs := make([]services.PlanDocument, 0, len(docs))
+	docIds := make([]string, 0, len(docs))
+	for _, d := range docs {
+		pages := d.PageCount
+		if pages == 0 {
+			var count int64
+			db.DB.Model(&db.DocumentPage{}).Where("document_id = ?", d.ID).Count(&count)
+			pages = int(count)
+		}
+		if pages == 0 {
+			pages = 1 // unknown length; read it as one unit
+		}
+		planDocs = append(planDocs, services.PlanDocument{ID: d.ID, Filename: d.Filename, Pages: pages})
+		docIds = append(docIds, d.ID)
+	}
+
+	var decks int64
+	db.DB.Model(&db.FlashcardDeck{}).
+		Where("user_id = ? AND (goal_id = ? OR document_id IN ?)", userId, goal.ID, docIds).
+		Count(&decks)
+
+	req := services.PlanRequest{
+		GoalTitle:    goal.Title,
+		Start:        time.Now(),
+		Target:       *goal.TargetDate,
+		Availability: availability,
+		Documents:    planDocs,
+		Flashcards:   decks > 0,
+		Revision:     1,
+	}
+	if hasPrevious {
+		req.PagesRead = previous.PagesRead()
+		req.Revision = previous.Revision + 1
+	}
+
+	plan, err := services.GenerateStudyPlan(req)
+	if err != nil {
+		var llmErr *services.LLMError
+		if errors.As(err, &llmErr) {
+			respondLLMError(c, "failed to generate plan", err)
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveGoalPlan(&goal, plan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal_id":      goal.ID,
+		"plan":         plan,
+		"behind":       false,
+		"generated_at": goal.PlanGeneratedAt,
+	})
+}
+
+// UpdatePlanTask marks a plan task done (body: {"done": true}) or not done
+func UpdatePlanTask(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Done *bool `json:"done"`
+	}
+	if err := c.BindJSON(&body); err != nil || body.Done == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "done is required"})
+		return
+	}
+
+	var goal db.Goal
+	if err := db.DB.Where("id = ? AND user_id = ?", c.Param("goal_id"), userId).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+		return
+	}
+
+	var plan services.StudyPlan
+	if goal.AIPlan == nil || json.Unmarshal([]byte(*goal.AIPlan), &plan) != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal has no plan yet"})
+		return
+	}
+	if !plan.SetTaskDone(c.Param("task_id"), *body.Done) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	raw, err := json.Marshal(plan)
+	if err == nil {
+		err = db.DB.Model(&goal).Update("ai_plan", string(raw)).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal_id": goal.ID,
+		"plan":    plan,
+		"behind":  plan.Behind(time.Now()),
+	})
+}
+
+func saveGoalPlan(goal *db.Goal, plan *services.StudyPlan) error {
+	raw, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	planJSON := string(raw)
+	now := time.Now()
+	goal.AIPlan = &planJSON
+	goal.PlanGeneratedAt = &now
+	return db.DB.Model(goal).Updates(map[string]interface{}{
+		"ai_plan":           planJSON,
+		"plan_generated_at": now,
+	}).Error
+}
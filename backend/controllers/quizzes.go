@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"skillup-backend/db"
 	"skillup-backend/services"
@@ -26,6 +27,15 @@ func GenerateQuiz(c *gin.Context) {
 		}
 	}
 
+	if err := config.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if config.PageFrom < 0 || config.PageTo < 0 || (config.PageTo > 0 && config.PageFrom > config.PageTo) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page range"})
+		return
+	}
+
 	// Fetch document
 	var doc db.Document
 	if err := db.DB.Where("id = ? AND user_id = ?", documentId, userId).First(&doc).Error; err != nil {
@@ -39,17 +49,26 @@ func GenerateQuiz(c *gin.Context) {
 		return
 	}
 
-	// Fetch document text
-	var docRaw db.DocumentRaw
-	if err := db.DB.Where("document_id = ?", documentId).First(&docRaw).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "document text not found"})
+	// Sample material across the document, avoiding earlier questions
+	previous, err := services.PreviousQuizQuestions(userId, documentId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load previous quizzes"})
+		return
+	}
+	chunks, err := services.SelectQuizChunks(userId, documentId, config, previous)
+	if errors.Is(err, services.ErrNoQuizMaterial) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondLLMError(c, "failed to select quiz material", err)
 		return
 	}
 
 	// Generate quiz using LLM
-	questions, err := services.GenerateQuizFromDocument(docRaw.Text, config)
+	questions, err := services.GenerateQuizFromDocument(chunks, previous, config)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate quiz: " + err.Error()})
+		respondLLMError(c, "failed to generate quiz", err)
 		return
 	}
 
@@ -76,19 +95,10 @@ func GenerateQuiz(c *gin.Context) {
 	}
 
 	// Return quiz without correct answers for frontend
-	questionsForUser := make([]map[string]interface{}, len(questions))
-	for i, q := range questions {
-		questionsForUser[i] = map[string]interface{}{
-			"id":       q.ID,
-			"question": q.Question,
-			"options":  q.Options,
-		}
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"id":              quiz.ID,
 		"document_id":     quiz.DocumentID,
-		"questions":       questionsForUser,
+		"questions":       questionsForUser(questions),
 		"total_questions": quiz.TotalQuestions,
 		"status":          quiz.Status,
 	})
@@ -114,18 +124,10 @@ func GetQuiz(c *gin.Context) {
 
 	// If quiz not submitted, hide correct answers
 	if quiz.Status != "submitted" {
-		questionsForUser := make([]map[string]interface{}, len(questions))
-		for i, q := range questions {
-			questionsForUser[i] = map[string]interface{}{
-				"id":       q.ID,
-				"question": q.Question,
-				"options":  q.Options,
-			}
-		}
 		c.JSON(http.StatusOK, gin.H{
 			"id":              quiz.ID,
 			"document_id":     quiz.DocumentID,
-			"questions":       questionsForUser,
+			"questions":       questionsForUser(questions),
 			"total_questions": quiz.TotalQuestions,
 			"status":          quiz.Status,
 		})
@@ -170,7 +172,11 @@ func SubmitQuiz(c *gin.Context) {
 	}
 
 	// Calculate score
-	score, feedback := services.CalculateQuizScore(questions, body.Answers)
+	score, feedback, err := services.CalculateQuizScore(questions, body.Answers)
+	if err != nil {
+		respondLLMError(c, "failed to grade quiz", err)
+		return
+	}
 
 	// Save user answers
 	answersJSON, err := json.Marshal(body.Answers)
@@ -230,3 +236,17 @@ func GetDocumentQuizzes(c *gin.Context) {
 
 	c.JSON(http.StatusOK, quizzes)
 }
+
+// questionsForUser strips answers, rubrics and sources from questions
+func questionsForUser(questions []services.Question) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(questions))
+	for i, q := range questions {
+		out[i] = map[string]interface{}{
+			"id":       q.ID,
+			"type":     q.QuestionType(),
+			"question": q.Question,
+			"options":  q.Options,
+		}
+	}
+	return out
+}
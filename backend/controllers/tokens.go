@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"skillup-backend/db"
+	"skillup-backend/services"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAccessTokens lists the user's personal access tokens, without their secrets
+func GetAccessTokens(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var tokens []db.PersonalAccessToken
+	if err := db.DB.Where("user_id = ?", userId).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch access tokens"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, accessTokenJSON(&tokens[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": result, "available_scopes": services.TokenScopes()})
+}
+
+// CreateAccessToken creates a named token with scopes and an optional
+// lifetime. The secret is only returned in this response.
+func CreateAccessToken(c *gin.Context) {
+	userId := c.GetString("user_id")
+
+	var body struct {
+		Name          string   `json:"name" binding:"required,max=100"`
+		Scopes        []string `json:"scopes" binding:"required"`
+		ExpiresInDays *int     `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // omitted: never expires
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	scopes, err := services.ValidateScopes(body.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var expiresAt *time.Time
+	if body.ExpiresInDays != nil {
+		t := time.Now().AddDate(0, 0, *body.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	token, secret, err := services.CreateAccessToken(userId, name, scopes, expiresAt)
+	if errors.Is(err, services.ErrTooManyAccessTokens) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create access token"})
+		return
+	}
+
+	result := accessTokenJSON(token)
+	result["token"] = secret
+	c.JSON(http.StatusCreated, result)
+}
+
+// DeleteAccessToken revokes one of the user's personal access tokens
+func DeleteAccessToken(c *gin.Context) {
+	userId := c.GetString("user_id")
+	tokenId := c.Param("token_id")
+
+	res := db.DB.Where("id = ? AND user_id = ?", tokenId, userId).Delete(&db.PersonalAccessToken{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete access token"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "access token not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func accessTokenJSON(token *db.PersonalAccessToken) gin.H {
+	var scopes []string
+	_ = json.Unmarshal(token.Scopes, &scopes)
+	return gin.H{
+		"id":           token.ID,
+		"name":         token.Name,
+		"prefix":       token.Prefix,
+		"scopes":       scopes,
+		"expires_at":   token.ExpiresAt,
+		"last_used_at": token.LastUsedAt,
+		"created_at":   token.CreatedAt,
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import "gorm.io/gorm"
+
+// DeleteDocumentCascade removes a document and everything derived from it:
+// stored file and text, pages, chunks, ingestion jobs, quizzes and its
+// collection/goal links. Flashcard decks and cards survive without their
+// document reference, aliases of the document become ordinary documents and
+// chat scopes forget the document. Run it inside a transaction.
+func DeleteDocumentCascade(tx *gorm.DB, documentID string) error {
+	for _, model := range []interface{}{
+		&DocumentRaw{},
+		&DocumentPage{},
+		&DocumentChunk{},
+		&IngestionJob{},
+		&Quiz{},
+		&CollectionDocument{},
+		&GoalDocument{},
+	} {
+		if err := tx.Where("document_id = ?", documentID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&FlashcardDeck{}).Where("document_id = ?", documentID).
+		Update("document_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Flashcard{}).
+		Where("source_chunk_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM document_chunks c WHERE c.id = flashcards.source_chunk_id)").
+		Update("source_chunk_id", nil).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&Document{}).Where("duplicate_of = ?", documentID).
+		Update("duplicate_of", nil).Error; err != nil {
+		return err
+	}
+
+	// scope_document_ids is a JSON array of IDs
+	if err := tx.Exec(`UPDATE conversations SET scope_document_ids = scope_document_ids - ?::text
+		WHERE scope_document_ids @> jsonb_build_array(?::text)`, documentID, documentID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`UPDATE conversations SET scope_document_ids = NULL
+		WHERE scope_document_ids = '[]'::jsonb`).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("id = ?", documentID).Delete(&Document{}).Error
+}
+
+// DeleteUserCascade removes a user account and everything it owns:
+// documents and all data derived from them, goals, topics, collections,
+// quizzes, flashcards, study activity, conversations, sessions, personal
+// access tokens and linked identities. Denylisted access tokens are kept
+// until they expire, so revoke the user's sessions first. Run it inside a
+// transaction.
+func DeleteUserCascade(tx *gorm.DB, userID string) error {
+	// Link tables have no user_id; go through the owning rows
+	links := []struct {
+		model  interface{}
+		column string
+		owners string
+	}{
+		{&CollectionDocument{}, "collection_id", "SELECT id FROM collections WHERE user_id = ?"},
+		{&CollectionDocument{}, "document_id", "SELECT id FROM documents WHERE user_id = ?"},
+		{&GoalDocument{}, "goal_id", "SELECT id FROM goals WHERE user_id = ?"},
+		{&GoalDocument{}, "document_id", "SELECT id FROM documents WHERE user_id = ?"},
+	}
+	for _, l := range links {
+		if err := tx.Where(l.column+" IN ("+l.owners+")", userID).Delete(l.model).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, model := range []interface{}{
+		&FlashcardReview{},
+		&Flashcard{},
+		&FlashcardDeck{},
+		&Quiz{},
+		&ChatMessage{},
+		&Conversation{},
+		&StudyActivity{},
+		&IngestionJob{},
+		&DocumentChunk{},
+		&DocumentPage{},
+		&DocumentRaw{},
+		&Document{},
+		&Collection{},
+		&Topic{},
+		&Goal{},
+		&RefreshToken{},
+		&UserToken{},
+		&UserIdentity{},
+		&PersonalAccessToken{},
+	} {
+		if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Where("id = ?", userID).Delete(&User{}).Error
+}
@@ -30,20 +30,96 @@ func Connect(){
 	Migrate()
 }
 
+// staleConstraints are check constraints whose allowed values have changed.
+// AutoMigrate never alters an existing constraint, so they are dropped and
+// recreated from the current struct tags.
+var staleConstraints = []struct{ table, name string }{
+	{"documents", "chk_documents_processing_status"},
+	{"goals", "chk_goals_status"},
+	{"user_tokens", "chk_user_tokens_purpose"},
+}
+
 func Migrate() {
+	for _, sc := range staleConstraints {
+		if err := DB.Exec("ALTER TABLE IF EXISTS " + sc.table + " DROP CONSTRAINT IF EXISTS " + sc.name).Error; err != nil {
+			log.Println("warning: couldn't drop constraint", sc.name+":", err)
+		}
+	}
+
 	if err := DB.AutoMigrate(
 		&User{},
+		&UserToken{},
+		&UserIdentity{},
+		&OIDCLoginState{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&PersonalAccessToken{},
 		&Goal{},
 		&Topic{},
 		&Document{},
+		&Collection{},
+		&CollectionDocument{},
+		&GoalDocument{},
 		&DocumentRaw{},
+		&DocumentPage{},
 		&DocumentChunk{},
+		&IngestionJob{},
 		&Quiz{},
+		&FlashcardDeck{},
+		&Flashcard{},
+		&FlashcardReview{},
 		&StudyActivity{},
+		&Conversation{},
 		&ChatMessage{},
 	); err != nil {
 		log.Fatal("migration failed:", err)
 	}
+
+	migrateSearchIndexes()
+	migrateContentHashes()
+	migrateJobErrors()
+}
+
+// migrateJobErrors removes URLs from errors stored by older versions, which
+// kept raw provider errors whose request URLs could include an API key
+func migrateJobErrors() {
+	if err := DB.Exec(`UPDATE ingestion_jobs SET last_error = regexp_replace(last_error, 'https?://\S+', '[url]', 'g')
+		WHERE last_error ~ 'https?://'`).Error; err != nil {
+		log.Println("warning: couldn't clean up ingestion job errors:", err)
+	}
+}
+
+// migrateContentHashes fills in the file hash of documents uploaded before
+// deduplication, so they can be matched by later uploads
+func migrateContentHashes() {
+	stmts := []string{
+		`UPDATE document_raws SET content_hash = encode(sha256(file_data), 'hex')
+			WHERE (content_hash IS NULL OR content_hash = '') AND file_data IS NOT NULL AND length(file_data) > 0`,
+		`UPDATE documents d SET content_hash = r.content_hash FROM document_raws r
+			WHERE r.document_id = d.id AND (d.content_hash IS NULL OR d.content_hash = '') AND r.content_hash <> ''`,
+	}
+	for _, stmt := range stmts {
+		if err := DB.Exec(stmt).Error; err != nil {
+			log.Println("warning: couldn't backfill content hashes:", err)
+			return
+		}
+	}
+}
+
+// migrateSearchIndexes adds the full-text search column used by hybrid retrieval.
+// It is a generated column, so it is never out of date with chunk_text and
+// GORM models don't need to know about it.
+func migrateSearchIndexes() {
+	stmts := []string{
+		`ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (to_tsvector('english', chunk_text)) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_document_chunks_search_vector ON document_chunks USING GIN (search_vector)`,
+	}
+	for _, stmt := range stmts {
+		if err := DB.Exec(stmt).Error; err != nil {
+			log.Fatal("migration failed:", err)
+		}
+	}
 }
 
 func GetDB() *gorm.DB {
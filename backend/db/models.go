@@ -9,21 +9,92 @@ import (
 
 // Users
 type User struct {
+	ID              string `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Email           string `gorm:"uniqueIndex;size:255;not null"`
+	Name            string `gorm:"size:100"`
+	Password        string `gorm:"size:255;not null"`
+	EmailVerifiedAt *time.Time
+	PendingEmail    string    `gorm:"size:255"` // new address awaiting verification
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// Single-use tokens emailed to a user for password resets, email verification
+// and email changes, stored as an HMAC so a database leak does not expose them
+type UserToken struct {
 	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	Email     string    `gorm:"uniqueIndex;size:255;not null"`
-	Name      string    `gorm:"size:100"`
-	Password  string    `gorm:"size:255;not null"` 
+	UserID    string    `gorm:"index;not null"`
+	Purpose   string    `gorm:"type:varchar(30);not null;check:purpose IN ('password_reset','email_verification','email_change')"`
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
 
+// Links a user to an account at an OpenID Connect provider
+type UserIdentity struct {
+	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string    `gorm:"index;not null"`
+	Issuer    string    `gorm:"size:255;uniqueIndex:idx_user_identities_issuer_subject;not null"`
+	Subject   string    `gorm:"size:255;uniqueIndex:idx_user_identities_issuer_subject;not null"` // the provider's "sub"
+	Email     string    `gorm:"size:255"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Pending OpenID Connect sign-in, from the redirect to the provider until
+// its callback. State is the random "state" parameter.
+type OIDCLoginState struct {
+	State        string    `gorm:"primaryKey;size:64"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"` // PKCE
+	ExpiresAt    time.Time `gorm:"index;not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// Refresh tokens, stored hashed. Each login starts a family (the session);
+// every refresh rotates to a new token in the same family.
+type RefreshToken struct {
+	ID        string     `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string     `gorm:"index;not null"`
+	FamilyID  string     `gorm:"type:uuid;index;not null"` // session ID, the "sid" claim of access tokens
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null"`
+	AccessJTI string     `gorm:"size:64"` // access token issued with this refresh token
+	UserAgent string     `gorm:"size:255"`
+	ExpiresAt time.Time  `gorm:"not null"`
+	UsedAt    *time.Time // set when rotated; presenting it again is reuse
+	RevokedAt *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// Denylist of revoked access tokens, kept until the token would have expired
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64"`
+	UserID    string    `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+	RevokedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// PersonalAccessToken is a token a user creates for scripts and integrations,
+// stored hashed. Scopes limit which routes it can call; see services.TokenScopes.
+type PersonalAccessToken struct {
+	ID         string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string         `gorm:"index;not null"`
+	Name       string         `gorm:"size:100;not null"`
+	Prefix     string         `gorm:"size:16;not null"` // start of the token, shown so users can tell tokens apart
+	TokenHash  string         `gorm:"size:64;uniqueIndex;not null"`
+	Scopes     datatypes.JSON `gorm:"type:jsonb;not null"` // []string
+	ExpiresAt  *time.Time     // nil never expires
+	LastUsedAt *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
 // Goals
 type Goal struct {
 	ID              string     `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	UserID          string     `gorm:"index;not null"`
 	Title           string     `gorm:"not null"`
 	TargetDate      *time.Time
-	Status          string     `gorm:"type:text;check:status IN ('active','completed')"`
-	AIPlan          *string    `gorm:"type:jsonb"` // AI-generated study plan (future)
+	Status          string     `gorm:"type:text;check:status IN ('active','completed','archived')"`
+	AIPlan          *string    `gorm:"type:jsonb"` // AI-generated study plan (services.StudyPlan)
 	PlanGeneratedAt *time.Time // When plan was generated
 	CreatedAt       time.Time  `gorm:"autoCreateTime"`
 }
@@ -46,19 +117,65 @@ type Document struct {
 	UserID             string     `gorm:"index;not null"`
 	Filename           string     `gorm:"size:255;not null"`
 	FilePath           string     `gorm:"size:500"` // if we later add S3
-	ProcessingStatus   string     `gorm:"type:varchar(20);default:'uploaded';check:processing_status IN ('uploaded','processed','failed')"`
+	MimeType           string     `gorm:"size:100"` // detected on upload; empty for older PDFs
+	ContentHash        string     `gorm:"size:64;index"` // hex SHA-256 of the uploaded file
+	DuplicateOf        *string    `gorm:"type:uuid;index"` // the user's document this was uploaded again as an alias of
+	ProcessingStatus   string     `gorm:"type:varchar(20);default:'uploaded';check:processing_status IN ('uploaded','processing','processed','failed')"`
+	Title              string     `gorm:"size:500"` // from file metadata, if present
+	Author             string     `gorm:"size:500"`
+	PageCount          int
+	ChunkStrategy      string         `gorm:"size:20"`     // chunker used for the stored chunks
+	ChunkParams        datatypes.JSON `gorm:"type:jsonb"` // services.ChunkOptions
+	EmbeddingModel     string         `gorm:"size:100"`   // model that produced the stored embeddings
 	Summary            string     `gorm:"type:text"` // AI-generated summary
+	SummaryPoints      datatypes.JSON `gorm:"type:jsonb"` // []services.SummaryPoint with their source sections
 	SummaryGeneratedAt *time.Time // When summary was generated
 	UploadDate         time.Time  `gorm:"autoCreateTime"`
 }
 
+// Collections group documents, e.g. all material for one course
+type Collection struct {
+	ID          string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID      string    `gorm:"index;not null"`
+	Name        string    `gorm:"size:200;not null"`
+	Description string    `gorm:"type:text"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+// Documents in a collection
+type CollectionDocument struct {
+	CollectionID string    `gorm:"primaryKey;type:uuid"`
+	DocumentID   string    `gorm:"primaryKey;type:uuid;index"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
+
+// Documents linked to a goal
+type GoalDocument struct {
+	GoalID     string    `gorm:"primaryKey;type:uuid"`
+	DocumentID string    `gorm:"primaryKey;type:uuid;index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
 // Raw full-text extracted from document (optional)
 type DocumentRaw struct {
+	ID          string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	DocumentID  string         `gorm:"index;not null"`
+	UserID      string         `gorm:"index;not null"`
+	Text        string         `gorm:"type:text;not null"`
+	FileData    []byte         `gorm:"type:bytea"` // Store original uploaded file
+	Cues        datatypes.JSON `gorm:"type:jsonb"` // []services.TranscriptCue for subtitle files
+	ContentHash string         `gorm:"size:64;index"` // hex SHA-256 of FileData
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+}
+
+// Per-page text extracted from a document
+type DocumentPage struct {
 	ID         string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	DocumentID string    `gorm:"index;not null"`
+	DocumentID string    `gorm:"uniqueIndex:idx_document_pages_document_page;not null"`
 	UserID     string    `gorm:"index;not null"`
+	PageNumber int       `gorm:"uniqueIndex:idx_document_pages_document_page;not null"` // 1-based
 	Text       string    `gorm:"type:text;not null"`
-	FileData   []byte    `gorm:"type:bytea"` // Store original PDF file
+	CharStart  int       // offset (in characters) of the page within DocumentRaw.Text
 	CreatedAt  time.Time `gorm:"autoCreateTime"`
 }
 
@@ -67,11 +184,39 @@ type DocumentChunk struct {
 	ID        string             `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	DocumentID string            `gorm:"index;not null"`
 	UserID    string             `gorm:"index;not null"`
+	ChunkIndex int               `gorm:"not null;default:0"` // position within the document
 	ChunkText string             `gorm:"type:text;not null"`
+	PageStart *int               // first page the chunk covers (1-based), when known
+	PageEnd   *int               // last page the chunk covers
+	StartMs   *int               // time range of transcript chunks, in milliseconds
+	EndMs     *int
+	CharStart int                // character offsets of the chunk within DocumentRaw.Text
+	CharEnd   int
 	Embedding pgvector.Vector    `gorm:"type:vector;size:1536"` // pgvector-go Vector
 	CreatedAt time.Time          `gorm:"autoCreateTime"`
 }
 
+// Background ingestion job (extract -> chunk -> embed) for one document
+type IngestionJob struct {
+	ID          string     `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	DocumentID  string     `gorm:"index;not null"`
+	UserID      string     `gorm:"index;not null"`
+	Status      string     `gorm:"type:varchar(20);default:'queued';index;check:status IN ('queued','running','succeeded','failed')"`
+	Stage       string     `gorm:"type:varchar(20);default:'queued'"` // queued/extracting/chunking/embedding/done
+	Progress    int        `gorm:"default:0"`                         // percent complete, 0-100
+	ChunksTotal int
+	ChunksDone  int
+	Attempts    int        `gorm:"default:0"`
+	MaxAttempts int        `gorm:"default:5"`
+	NextRunAt   time.Time  `gorm:"index"`
+	HeartbeatAt *time.Time // Refreshed while running; stale jobs are requeued
+	LastError   string     `gorm:"type:text"`
+	StartedAt   *time.Time
+	FinishedAt  *time.Time
+	CreatedAt   time.Time  `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime"`
+}
+
 // Quizzes
 type Quiz struct {
 	ID             string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -86,6 +231,48 @@ type Quiz struct {
 	CreatedAt      time.Time      `gorm:"autoCreateTime"`
 }
 
+// Flashcard decks, optionally tied to a document or goal
+type FlashcardDeck struct {
+	ID         string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string    `gorm:"index;not null"`
+	Name       string    `gorm:"size:200;not null"`
+	DocumentID *string   `gorm:"type:uuid;index"`
+	GoalID     *string   `gorm:"type:uuid;index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// Flashcards with their SM-2 scheduling state
+type Flashcard struct {
+	ID             string  `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID         string  `gorm:"index:idx_flashcards_user_due,priority:1;not null"`
+	DeckID         string  `gorm:"type:uuid;index;not null"`
+	Front          string  `gorm:"type:text;not null"`
+	Back           string  `gorm:"type:text;not null"`
+	SourceChunkID  *string `gorm:"type:uuid"` // chunk a generated card came from
+	PageStart      *int
+	PageEnd        *int
+	StartMs        *int // transcript time range, for cards from subtitle files
+	EndMs          *int
+	EaseFactor     float64   `gorm:"not null;default:2.5"`
+	IntervalDays   int       `gorm:"not null;default:0"`
+	Repetitions    int       `gorm:"not null;default:0"` // successful reviews in a row
+	DueAt          time.Time `gorm:"index:idx_flashcards_user_due,priority:2;not null"`
+	LastReviewedAt *time.Time
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime"`
+}
+
+// One review of a flashcard, with the schedule it produced
+type FlashcardReview struct {
+	ID           string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID       string    `gorm:"index;not null"`
+	FlashcardID  string    `gorm:"type:uuid;index;not null"`
+	Grade        int       `gorm:"not null;check:grade BETWEEN 0 AND 5"`
+	EaseFactor   float64   `gorm:"not null"`
+	IntervalDays int       `gorm:"not null"`
+	ReviewedAt   time.Time `gorm:"autoCreateTime"`
+}
+
 // Study activity log
 type StudyActivity struct {
 	ID             string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
@@ -97,12 +284,26 @@ type StudyActivity struct {
 	CreatedAt      time.Time `gorm:"autoCreateTime"`
 }
 
+// Chat conversations (threads of chat messages)
+type Conversation struct {
+	ID                string         `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID            string         `gorm:"index;not null"`
+	Title             string         `gorm:"size:200"`
+	ScopeDocumentIDs  datatypes.JSON `gorm:"type:jsonb"` // retrieval scope remembered for follow-ups; all NULL = every document
+	ScopeCollectionID *string
+	ScopeGoalID       *string
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
+
 // Chat messages (user question + answer)
 type ChatMessage struct {
 	ID        string    `gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	UserID    string    `gorm:"index;not null"`
+	ConversationID *string `gorm:"index"` // NULL for messages sent outside a conversation
 	Question  string    `gorm:"type:text;not null"`
+	SearchQuery string  `gorm:"type:text"` // standalone rewrite of a follow-up, used for retrieval
 	Answer    string    `gorm:"type:text;not null"`
-	Sources   string    `gorm:"type:jsonb"`
+	Sources   datatypes.JSON `gorm:"type:jsonb"` // []services.Citation
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 }
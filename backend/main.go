@@ -6,6 +6,7 @@ import (
 	"skillup-backend/db"
 	"skillup-backend/middleware"
 	"skillup-backend/routes"
+	"skillup-backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,6 +19,17 @@ func main() {
 	db.Connect()
 	defer db.Close()
 
+	// Select LLM provider and mailer
+	services.InitLLM()
+	services.InitMailer()
+	services.CheckEmbeddingDimension()
+
+	// Background document ingestion
+	services.StartIngestionWorkers(config.AppConfig.INGEST_WORKERS)
+
+	// Purge expired refresh tokens and revoked access tokens
+	services.StartTokenCleanup()
+
 	// Setup Gin router
 	r := gin.Default()
 
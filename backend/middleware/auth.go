@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"skillup-backend/services"
 	"skillup-backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -21,15 +23,83 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
+		if strings.HasPrefix(tokenString, services.AccessTokenPrefix) {
+			token, scopes, err := services.AuthenticateAccessToken(tokenString)
+			if errors.Is(err, services.ErrInvalidAccessToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check token"})
+				c.Abort()
+				return
+			}
+			c.Set("user_id", token.UserID)
+			c.Set("access_token_id", token.ID)
+			c.Set("token_scopes", scopes)
+			c.Next()
+			return
+		}
+
 		claims, err := utils.ValidateJWT(tokenString)
-		if err != nil {
+		// tokens without a jti predate revocation and are no longer accepted
+		if err != nil || claims.RegisteredClaims.ID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := services.IsTokenRevoked(claims.RegisteredClaims.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.ID)
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_id", claims.RegisteredClaims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+
+		c.Next()
+	}
+}
 
+// RequireScope limits personal access tokens to routes of a resource their
+// scopes allow: GET and HEAD requests need read access, anything else write
+// access. Login sessions may call every route.
+func RequireScope(resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, isAccessToken := c.Get("token_scopes")
+		if !isAccessToken {
+			c.Next()
+			return
+		}
+		write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+		if !services.ScopeAllows(scopes.([]string), resource, write) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access token lacks the required scope"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession rejects personal access tokens, for account and token
+// management routes that only a logged-in user may call
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAccessToken := c.Get("token_scopes"); isAccessToken {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not available to access tokens"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -38,7 +108,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"skillup-backend/config"
+	"skillup-backend/db"
+	"skillup-backend/services"
+	"skillup-backend/utils"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func useTestConfig(t *testing.T) {
+	t.Helper()
+	prev := config.AppConfig
+	config.AppConfig.JWT_SECRET = "test-secret"
+	config.AppConfig.ACCESS_TOKEN_TTL = 15 * time.Minute
+	t.Cleanup(func() { config.AppConfig = prev })
+}
+
+// useTestDB points db.DB at the Postgres database in TEST_DATABASE_URL.
+// Tests that need it are skipped without one.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(&db.RevokedToken{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		db.DB = prev
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// authRequest sends a request with the bearer token through AuthMiddleware
+// and returns the status and the user ID the handler saw
+func authRequest(t *testing.T, token string) (int, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/me", AuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id"))
+	})
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+func TestAuthMiddlewareRequiresJTI(t *testing.T) {
+	useTestConfig(t)
+	claims := &utils.Claims{
+		ID:        uuid.NewString(),
+		SessionID: uuid.NewString(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JWT_SECRET))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := authRequest(t, token); code != http.StatusUnauthorized {
+		t.Errorf("token without a jti: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthMiddlewareDenylist(t *testing.T) {
+	useTestConfig(t)
+	useTestDB(t)
+	userID := uuid.NewString()
+	token, claims, err := utils.GenerateJWT(userID, uuid.NewString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Where("user_id = ?", userID).Delete(&db.RevokedToken{}) })
+
+	if code, got := authRequest(t, token); code != http.StatusOK || got != userID {
+		t.Fatalf("valid token: status = %d, user = %q, want %d for %q", code, got, http.StatusOK, userID)
+	}
+	if err := services.RevokeAccessToken(userID, claims.RegisteredClaims.ID, claims.ExpiresAt.Time); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := authRequest(t, token); code != http.StatusUnauthorized {
+		t.Errorf("denylisted token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
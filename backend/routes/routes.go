@@ -10,36 +10,109 @@ func SetupRoutes(r *gin.Engine) {
 	// Public routes (no auth required)
 	r.POST("/api/auth/signup", controllers.Signup)
 	r.POST("/api/auth/login", controllers.Login)
+	r.POST("/api/auth/refresh", controllers.RefreshToken)
+	r.POST("/api/auth/forgot-password", controllers.ForgotPassword)
+	r.POST("/api/auth/reset-password", controllers.ResetPassword)
+	r.POST("/api/auth/verify-email", controllers.VerifyEmail)
+	r.GET("/api/auth/oidc/login", controllers.OIDCLogin)
+	r.GET("/api/auth/oidc/callback", controllers.OIDCCallback)
 
-	// Protected routes (auth required)
+	// Protected routes (auth required). Personal access tokens are accepted
+	// too, limited to the route groups their scopes allow.
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware())
 
+	// Account, token management and legacy routes need a login session
+	session := api.Group("", middleware.RequireSession())
+	goals := api.Group("", middleware.RequireScope("goals"))
+	documents := api.Group("", middleware.RequireScope("documents"))
+	collections := api.Group("", middleware.RequireScope("collections"))
+	chat := api.Group("", middleware.RequireScope("chat"))
+	quizzes := api.Group("", middleware.RequireScope("quizzes"))
+	flashcards := api.Group("", middleware.RequireScope("flashcards"))
+
+	// Auth
+	session.POST("/auth/logout", controllers.Logout)
+	session.POST("/auth/verify-email/resend", controllers.ResendVerificationEmail)
+
+	// Account
+	session.GET("/me", controllers.GetMe)
+	session.PATCH("/me", controllers.UpdateMe)
+	session.DELETE("/me", controllers.DeleteMe)
+	session.POST("/me/email", controllers.ChangeEmail)
+	session.POST("/me/password", controllers.ChangePassword)
+
+	// Personal access tokens
+	session.GET("/tokens", controllers.GetAccessTokens)
+	session.POST("/tokens", controllers.CreateAccessToken)
+	session.DELETE("/tokens/:token_id", controllers.DeleteAccessToken)
+
 	// Goals
-	api.GET("/goals", controllers.GetGoals)
-	api.POST("/goals", controllers.CreateGoal)
+	goals.GET("/goals", controllers.GetGoals)
+	goals.POST("/goals", controllers.CreateGoal)
+	goals.GET("/goals/:goal_id", controllers.GetGoal)
+	goals.PATCH("/goals/:goal_id", controllers.UpdateGoal)
+	goals.DELETE("/goals/:goal_id", controllers.DeleteGoal)
+	goals.POST("/goals/:goal_id/documents", controllers.AddGoalDocuments)
+	goals.DELETE("/goals/:goal_id/documents/:document_id", controllers.RemoveGoalDocument)
+	goals.GET("/goals/:goal_id/plan", controllers.GetGoalPlan)
+	goals.POST("/goals/:goal_id/plan", controllers.GenerateGoalPlan)
+	goals.PATCH("/goals/:goal_id/plan/tasks/:task_id", controllers.UpdatePlanTask)
 
 	// Documents & PDF ingestion
-	api.POST("/documents/upload", controllers.UploadDocument)
-	api.GET("/documents", controllers.GetDocuments)
-	api.GET("/documents/:document_id", controllers.GetDocument)
-	api.GET("/documents/:document_id/file", controllers.GetDocumentFile)
-	api.POST("/documents/:document_id/summarize", controllers.SummarizeDocument)
+	documents.POST("/documents/upload", controllers.UploadDocument)
+	documents.GET("/documents", controllers.GetDocuments)
+	documents.GET("/documents/:document_id", controllers.GetDocument)
+	documents.PATCH("/documents/:document_id", controllers.UpdateDocument)
+	documents.DELETE("/documents/:document_id", controllers.DeleteDocument)
+	documents.POST("/documents/:document_id/reprocess", controllers.ReprocessDocument)
+	documents.GET("/documents/:document_id/file", controllers.GetDocumentFile)
+	documents.GET("/documents/:document_id/status", controllers.GetDocumentStatus)
+	documents.GET("/documents/:document_id/pages", controllers.GetDocumentPages)
+	documents.POST("/documents/:document_id/summarize", controllers.SummarizeDocument)
+
+	// Collections (groups of documents, usable as chat scope)
+	collections.GET("/collections", controllers.GetCollections)
+	collections.POST("/collections", controllers.CreateCollection)
+	collections.GET("/collections/:collection_id", controllers.GetCollection)
+	collections.DELETE("/collections/:collection_id", controllers.DeleteCollection)
+	collections.POST("/collections/:collection_id/documents", controllers.AddCollectionDocuments)
+	collections.DELETE("/collections/:collection_id/documents/:document_id", controllers.RemoveCollectionDocument)
 
 	// Chat (RAG)
-	api.POST("/chat/query", controllers.ChatQuery)
+	chat.POST("/chat/query", controllers.ChatQuery)
+	chat.POST("/chat/stream", controllers.ChatStream)
+
+	// Conversations
+	chat.POST("/conversations", controllers.CreateConversation)
+	chat.GET("/conversations", controllers.GetConversations)
+	chat.PATCH("/conversations/:conversation_id", controllers.UpdateConversation)
+	chat.DELETE("/conversations/:conversation_id", controllers.DeleteConversation)
+	chat.GET("/conversations/:conversation_id/messages", controllers.GetConversationMessages)
 
 	// Quizzes (NEW - document-based)
-	api.POST("/quizzes/generate/:document_id", controllers.GenerateQuiz)
-	api.GET("/quizzes/:quiz_id", controllers.GetQuiz)
-	api.POST("/quizzes/:quiz_id/submit", controllers.SubmitQuiz)
-	api.GET("/quizzes/document/:document_id", controllers.GetDocumentQuizzes)
-	api.GET("/quizzes", controllers.GetQuizzes)
+	quizzes.POST("/quizzes/generate/:document_id", controllers.GenerateQuiz)
+	quizzes.GET("/quizzes/:quiz_id", controllers.GetQuiz)
+	quizzes.POST("/quizzes/:quiz_id/submit", controllers.SubmitQuiz)
+	quizzes.GET("/quizzes/document/:document_id", controllers.GetDocumentQuizzes)
+	quizzes.GET("/quizzes", controllers.GetQuizzes)
+
+	// Flashcards (SM-2 spaced repetition)
+	flashcards.GET("/flashcards/decks", controllers.GetFlashcardDecks)
+	flashcards.POST("/flashcards/decks", controllers.CreateFlashcardDeck)
+	flashcards.GET("/flashcards/decks/:deck_id", controllers.GetFlashcardDeck)
+	flashcards.DELETE("/flashcards/decks/:deck_id", controllers.DeleteFlashcardDeck)
+	flashcards.POST("/flashcards/decks/:deck_id/generate", controllers.GenerateFlashcards)
+	flashcards.POST("/flashcards/decks/:deck_id/cards", controllers.CreateFlashcard)
+	flashcards.GET("/flashcards/due", controllers.GetDueFlashcards)
+	flashcards.PATCH("/flashcards/:flashcard_id", controllers.UpdateFlashcard)
+	flashcards.DELETE("/flashcards/:flashcard_id", controllers.DeleteFlashcard)
+	flashcards.POST("/flashcards/:flashcard_id/review", controllers.ReviewFlashcard)
 
 	// DEPRECATED ROUTES (keep for backward compatibility, but mark as legacy)
 	// These routes are kept but should not be enhanced
-	api.GET("/topics", controllers.GetTopics)           // DEPRECATED
-	api.POST("/topics", controllers.CreateTopic)        // DEPRECATED
-	api.POST("/activity", controllers.CreateActivity)   // DEPRECATED
-	api.GET("/activity", controllers.GetActivity)       // DEPRECATED
+	session.GET("/topics", controllers.GetTopics)           // DEPRECATED
+	session.POST("/topics", controllers.CreateTopic)        // DEPRECATED
+	session.POST("/activity", controllers.CreateActivity)   // DEPRECATED
+	session.GET("/activity", controllers.GetActivity)       // DEPRECATED
 }
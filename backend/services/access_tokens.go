@@ -0,0 +1,148 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"skillup-backend/db"
+	"skillup-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccessTokenPrefix starts every personal access token, so the auth
+// middleware can tell them from JWTs and secret scanners can find leaked ones
+const AccessTokenPrefix = "sku_"
+
+const (
+	maxAccessTokensPerUser = 50
+	// lastUsedResolution limits last_used_at writes to one per token per minute
+	lastUsedResolution = time.Minute
+)
+
+// scopeResources are the route groups a personal access token can be
+// granted. Each takes "<resource>:read", "<resource>:write" or "<resource>:*";
+// "chat" covers chat queries and conversations and has no read/write split.
+var scopeResources = []string{"documents", "collections", "goals", "quizzes", "flashcards"}
+
+var (
+	ErrInvalidAccessToken  = errors.New("invalid or expired access token")
+	ErrTooManyAccessTokens = fmt.Errorf("a user can have at most %d access tokens", maxAccessTokensPerUser)
+)
+
+// TokenScopes lists every scope a personal access token can be created with
+func TokenScopes() []string {
+	scopes := make([]string, 0, len(scopeResources)*3+1)
+	for _, r := range scopeResources {
+		scopes = append(scopes, r+":read", r+":write", r+":*")
+	}
+	return append(scopes, "chat")
+}
+
+// ValidateScopes checks and deduplicates requested scopes
+func ValidateScopes(scopes []string) ([]string, error) {
+	known := map[string]bool{}
+	for _, s := range TokenScopes() {
+		known[s] = true
+	}
+	seen := map[string]bool{}
+	var valid []string
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if !known[s] {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			valid = append(valid, s)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+	return valid, nil
+}
+
+// ScopeAllows reports whether granted scopes permit reading (or, if write
+// is set, changing) a resource
+func ScopeAllows(granted []string, resource string, write bool) bool {
+	action := resource + ":read"
+	if write {
+		action = resource + ":write"
+	}
+	for _, s := range granted {
+		if s == resource || s == resource+":*" || s == action {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAccessToken creates a named personal access token and returns it
+// with its secret, which is shown once and never stored
+func CreateAccessToken(userID, name string, scopes []string, expiresAt *time.Time) (*db.PersonalAccessToken, string, error) {
+	var count int64
+	if err := db.DB.Model(&db.PersonalAccessToken{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count >= maxAccessTokensPerUser {
+		return nil, "", ErrTooManyAccessTokens
+	}
+
+	random, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret := AccessTokenPrefix + random
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := db.PersonalAccessToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    secret[:len(AccessTokenPrefix)+8],
+		TokenHash: utils.HashToken(secret),
+		Scopes:    scopesJSON,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.DB.Create(&token).Error; err != nil {
+		return nil, "", err
+	}
+	return &token, secret, nil
+}
+
+// AuthenticateAccessToken looks up an unexpired personal access token and
+// records that it was used
+func AuthenticateAccessToken(secret string) (*db.PersonalAccessToken, []string, error) {
+	var token db.PersonalAccessToken
+	err := db.DB.Where("token_hash = ?", utils.HashToken(secret)).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, nil, ErrInvalidAccessToken
+	}
+
+	var scopes []string
+	if err := json.Unmarshal(token.Scopes, &scopes); err != nil {
+		return nil, nil, err
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastUsedResolution {
+		if err := db.DB.Model(&token).Update("last_used_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return &token, scopes, nil
+}
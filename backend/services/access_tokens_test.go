@@ -0,0 +1,65 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  []string
+		resource string
+		write    bool
+		want     bool
+	}{
+		{"read allows read", []string{"documents:read"}, "documents", false, true},
+		{"read does not allow write", []string{"documents:read"}, "documents", true, false},
+		{"write allows write", []string{"documents:write"}, "documents", true, true},
+		{"write does not allow read", []string{"documents:write"}, "documents", false, false},
+		{"read and write", []string{"documents:read", "documents:write"}, "documents", true, true},
+		{"star allows read", []string{"quizzes:*"}, "quizzes", false, true},
+		{"star allows write", []string{"quizzes:*"}, "quizzes", true, true},
+		{"other resource", []string{"quizzes:*"}, "flashcards", false, false},
+		{"prefix of another resource", []string{"goals:*"}, "goal", false, false},
+		{"chat covers reads", []string{"chat"}, "chat", false, true},
+		{"chat covers writes", []string{"chat"}, "chat", true, true},
+		{"chat is not documents", []string{"chat"}, "documents", false, false},
+		{"nothing granted", nil, "documents", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeAllows(tt.granted, tt.resource, tt.write); got != tt.want {
+				t.Errorf("ScopeAllows(%v, %q, %v) = %v, want %v", tt.granted, tt.resource, tt.write, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		in      []string
+		want    []string
+		wantErr string
+	}{
+		{in: []string{"documents:read", " chat ", "documents:read"}, want: []string{"documents:read", "chat"}},
+		{in: []string{"goals:*"}, want: []string{"goals:*"}},
+		{in: []string{"documents"}, wantErr: "unknown scope"},
+		{in: []string{"chat:read"}, wantErr: "unknown scope"},
+		{in: []string{"admin:*"}, wantErr: "unknown scope"},
+		{in: nil, wantErr: "at least one scope"},
+	}
+	for _, tt := range tests {
+		got, err := ValidateScopes(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateScopes(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ValidateScopes(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
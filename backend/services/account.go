@@ -0,0 +1,342 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"skillup-backend/config"
+	"skillup-backend/db"
+	"skillup-backend/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Purposes of emailed user tokens
+const (
+	TokenPasswordReset     = "password_reset"
+	TokenEmailVerification = "email_verification"
+	TokenEmailChange       = "email_change"
+)
+
+const (
+	passwordResetTTL     = time.Hour
+	emailVerificationTTL = 48 * time.Hour
+	userTokenCooldown    = 5 * time.Minute // between reset or verification emails to one account
+)
+
+var (
+	// ErrInvalidUserToken covers unknown, expired and already used emailed tokens
+	ErrInvalidUserToken = errors.New("invalid or expired token")
+	ErrWrongPassword    = errors.New("current password is incorrect")
+	ErrEmailTaken       = errors.New("email already exists")
+	// ErrUserTokenCooldown means an email with a valid link was sent moments ago
+	ErrUserTokenCooldown = errors.New("an email was sent recently; please wait a few minutes before asking again")
+)
+
+// SendVerificationEmail emails the user a link confirming their address. It
+// fails with ErrUserTokenCooldown if a link that still works was sent within
+// the last few minutes.
+func SendVerificationEmail(user *db.User) error {
+	var token string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkUserTokenCooldown(tx, user.ID, TokenEmailVerification); err != nil {
+			return err
+		}
+		var err error
+		token, err = issueUserToken(tx, user.ID, TokenEmailVerification, emailVerificationTTL)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return GetMailer().Send(Email{
+		To:      user.Email,
+		Subject: "Verify your SkillUp email address",
+		Body: fmt.Sprintf(`Hi%s,
+
+Please confirm your email address by opening this link:
+
+%s
+
+The link expires in 48 hours. If you did not create a SkillUp account, you can ignore this email.
+`, greetingName(user), appLink("/verify-email", token)),
+	})
+}
+
+// SendPasswordResetEmail emails the user a link for choosing a new password,
+// or for setting a first one on accounts created through single sign-on.
+// Links sent earlier stop working. Like SendVerificationEmail it fails with
+// ErrUserTokenCooldown while a recent link still works.
+func SendPasswordResetEmail(user *db.User) error {
+	var token string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkUserTokenCooldown(tx, user.ID, TokenPasswordReset); err != nil {
+			return err
+		}
+		if err := tx.Model(&db.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, TokenPasswordReset).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		var err error
+		token, err = issueUserToken(tx, user.ID, TokenPasswordReset, passwordResetTTL)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if user.Password == "" {
+		return GetMailer().Send(Email{
+			To:      user.Email,
+			Subject: "Set a password for SkillUp",
+			Body: fmt.Sprintf(`Hi%s,
+
+Someone asked to add a password to your SkillUp account, which you sign in to through single sign-on. To choose a password, open this link:
+
+%s
+
+The link expires in an hour and can be used once. Setting a password signs out every session and deletes your personal access tokens. If you did not ask for this, you can ignore this email.
+`, greetingName(user), appLink("/reset-password", token)),
+		})
+	}
+	return GetMailer().Send(Email{
+		To:      user.Email,
+		Subject: "Reset your SkillUp password",
+		Body: fmt.Sprintf(`Hi%s,
+
+Someone asked to reset the password of your SkillUp account. To choose a new password, open this link:
+
+%s
+
+The link expires in an hour and can be used once. Choosing a new password signs out every session and deletes your personal access tokens. If you did not ask for a reset, you can ignore this email; your password has not changed.
+`, greetingName(user), appLink("/reset-password", token)),
+	})
+}
+
+// ResetPassword sets a new password using an emailed reset token. Every
+// session is signed out and personal access tokens are deleted, since whoever
+// knew the old password may have created them. The email address counts as
+// verified since the user received the link.
+func ResetPassword(token, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		ut, err := consumeUserToken(tx, token, TokenPasswordReset)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&db.User{}).Where("id = ?", ut.UserID).Update("password", string(hashed)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&db.User{}).Where("id = ? AND email_verified_at IS NULL", ut.UserID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", ut.UserID).Delete(&db.PersonalAccessToken{}).Error; err != nil {
+			return err
+		}
+		return revokeFamilies(tx, "user_id = ?", ut.UserID)
+	})
+}
+
+// VerifyEmail confirms an address using an emailed token: either the
+// account's own address after signup, or the new address of an email change
+func VerifyEmail(token string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		ut, err := consumeUserToken(tx, token, TokenEmailVerification, TokenEmailChange)
+		if err != nil {
+			return err
+		}
+		if ut.Purpose == TokenEmailVerification {
+			return tx.Model(&db.User{}).Where("id = ? AND email_verified_at IS NULL", ut.UserID).
+				Update("email_verified_at", time.Now()).Error
+		}
+
+		var user db.User
+		if err := tx.Where("id = ?", ut.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.PendingEmail == "" {
+			return ErrInvalidUserToken
+		}
+		if taken, err := emailTaken(tx, user.PendingEmail, user.ID); err != nil {
+			return err
+		} else if taken {
+			return ErrEmailTaken
+		}
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"email":             user.PendingEmail,
+			"pending_email":     "",
+			"email_verified_at": time.Now(),
+		}).Error
+	})
+}
+
+// RequestEmailChange records a new address and emails it a confirmation
+// link; the account keeps its current address until the link is opened.
+// Links sent for an earlier change stop working.
+func RequestEmailChange(user *db.User, email string) error {
+	email = strings.TrimSpace(email)
+	if taken, err := emailTaken(db.DB, email, user.ID); err != nil {
+		return err
+	} else if taken {
+		return ErrEmailTaken
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", user.ID, TokenEmailChange).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Model(user).Update("pending_email", email).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.PendingEmail = email
+
+	token, err := issueUserToken(db.DB, user.ID, TokenEmailChange, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return GetMailer().Send(Email{
+		To:      email,
+		Subject: "Confirm your new SkillUp email address",
+		Body: fmt.Sprintf(`Hi%s,
+
+To use this address for your SkillUp account, open this link:
+
+%s
+
+The link expires in 48 hours. Until then your account keeps using %s. If you did not ask for this change, you can ignore this email.
+`, greetingName(user), appLink("/verify-email", token), user.Email),
+	})
+}
+
+// ChangePassword sets a new password after checking the current one, and
+// signs out every other session. Accounts created through single sign-on
+// have no password to check; they set a first one through an emailed link
+// (SendPasswordResetEmail), so a stolen session cannot add one.
+func ChangePassword(user *db.User, sessionID, current, password string) error {
+	if !CheckPassword(user, current) {
+		return ErrWrongPassword
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := db.DB.Model(user).Update("password", string(hashed)).Error; err != nil {
+		return err
+	}
+	return RevokeOtherSessions(user.ID, sessionID)
+}
+
+// CheckPassword reports whether password is the user's current password
+func CheckPassword(user *db.User, password string) bool {
+	return user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+}
+
+// DeleteAccount signs the user out everywhere and deletes the account with
+// everything it owns in one transaction. It fails with ErrIngestionInProgress
+// while one of the user's documents is being processed.
+func DeleteAccount(userID string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := LockIngestionJobs(tx, "user_id = ?", userID); err != nil {
+			return err
+		}
+		if err := revokeFamilies(tx, "user_id = ?", userID); err != nil {
+			return err
+		}
+		return db.DeleteUserCascade(tx, userID)
+	})
+}
+
+// emailTaken reports whether another user already uses the address
+func emailTaken(tx *gorm.DB, email, exceptUserID string) (bool, error) {
+	var count int64
+	err := tx.Model(&db.User{}).Where("LOWER(email) = LOWER(?) AND id <> ?", email, exceptUserID).Count(&count).Error
+	return count > 0, err
+}
+
+// checkUserTokenCooldown refuses a new token while an unused, unexpired one
+// with the same purpose is less than userTokenCooldown old, so the email
+// endpoints cannot be used to flood an inbox. The user row is locked for the
+// rest of tx so concurrent requests are checked one at a time.
+func checkUserTokenCooldown(tx *gorm.DB, userID, purpose string) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ?", userID).First(&db.User{}).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	var count int64
+	if err := tx.Model(&db.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ? AND created_at > ?",
+			userID, purpose, now, now.Add(-userTokenCooldown)).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserTokenCooldown
+	}
+	return nil
+}
+
+func issueUserToken(tx *gorm.DB, userID, purpose string, ttl time.Duration) (string, error) {
+	token, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = tx.Create(&db.UserToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.SignToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error
+	return token, err
+}
+
+// consumeUserToken marks a valid token with one of the purposes used and
+// returns it. The conditional update makes the token single-use even under
+// concurrent requests.
+func consumeUserToken(tx *gorm.DB, token string, purposes ...string) (*db.UserToken, error) {
+	var ut db.UserToken
+	err := tx.Where("token_hash = ? AND purpose IN ?", utils.SignToken(token), purposes).First(&ut).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidUserToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := tx.Model(&db.UserToken{}).Where("id = ? AND used_at IS NULL AND expires_at > ?", ut.ID, now).Update("used_at", now)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+	return &ut, nil
+}
+
+// appLink builds a frontend URL carrying a token
+func appLink(path, token string) string {
+	return strings.TrimRight(config.AppConfig.APP_URL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func greetingName(user *db.User) string {
+	if user.Name == "" {
+		return ""
+	}
+	return " " + user.Name
+}
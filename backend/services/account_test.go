@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"regexp"
+	"skillup-backend/config"
+	"skillup-backend/db"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useTestDB points db.DB at the Postgres database in TEST_DATABASE_URL and
+// migrates the account and ingestion job tables. Tests that need it are
+// skipped without one.
+func useTestDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.AutoMigrate(
+		&db.User{},
+		&db.UserToken{},
+		&db.UserIdentity{},
+		&db.OIDCLoginState{},
+		&db.RefreshToken{},
+		&db.RevokedToken{},
+		&db.PersonalAccessToken{},
+		&db.IngestionJob{},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	prevDB, prevConfig := db.DB, config.AppConfig
+	db.DB = conn
+	config.AppConfig.JWT_SECRET = "test-secret"
+	config.AppConfig.APP_URL = "http://app.test"
+	config.AppConfig.ACCESS_TOKEN_TTL = 15 * time.Minute
+	config.AppConfig.REFRESH_TOKEN_TTL = 24 * time.Hour
+	t.Cleanup(func() {
+		db.DB, config.AppConfig = prevDB, prevConfig
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+}
+
+// fakeMailer records emails instead of sending them
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []Email
+}
+
+func (m *fakeMailer) Send(msg Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *fakeMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sent)
+}
+
+var emailToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// lastToken returns the token in the link of the last email sent
+func (m *fakeMailer) lastToken(t *testing.T) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := emailToken.FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+	if match == nil {
+		t.Fatalf("no link in email %q", m.sent[len(m.sent)-1].Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func useFakeMailer(t *testing.T) *fakeMailer {
+	t.Helper()
+	prev := GetMailer()
+	m := &fakeMailer{}
+	SetMailer(m)
+	t.Cleanup(func() { SetMailer(prev) })
+	return m
+}
+
+// createTestUser adds an unverified user with the given password
+func createTestUser(t *testing.T, password string) *db.User {
+	t.Helper()
+	user := &db.User{ID: uuid.NewString(), Email: uuid.NewString() + "@example.com", Name: "Test"}
+	if password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Password = string(hashed)
+	}
+	if err := db.DB.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// backdateUserTokens moves the user's tokens of a purpose back in time
+func backdateUserTokens(t *testing.T, userID, purpose string, by time.Duration) {
+	t.Helper()
+	if err := db.DB.Exec(`UPDATE user_tokens SET created_at = created_at - make_interval(secs => ?),
+		expires_at = expires_at - make_interval(secs => ?) WHERE user_id = ? AND purpose = ?`,
+		by.Seconds(), by.Seconds(), userID, purpose).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func reloadUser(t *testing.T, id string) *db.User {
+	t.Helper()
+	var user db.User
+	if err := db.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func TestPasswordReset(t *testing.T) {
+	useTestDB(t)
+	mailer := useFakeMailer(t)
+	user := createTestUser(t, "old-password")
+	session, err := StartSession(user.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, secret, err := CreateAccessToken(user.ID, "script", TokenScopes()[:1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SendPasswordResetEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	if mailer.sent[0].To != user.Email {
+		t.Errorf("email sent to %q, want %q", mailer.sent[0].To, user.Email)
+	}
+	token := mailer.lastToken(t)
+
+	if err := VerifyEmail(token); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("reset token used for verification: error = %v, want ErrInvalidUserToken", err)
+	}
+	if err := ResetPassword(token, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	updated := reloadUser(t, user.ID)
+	if !CheckPassword(updated, "new-password") || CheckPassword(updated, "old-password") {
+		t.Error("password was not changed")
+	}
+	if updated.EmailVerifiedAt == nil {
+		t.Error("a completed reset should verify the address")
+	}
+	if _, err := RefreshSession(session.RefreshToken, "test"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("session after the reset: error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := AuthenticateAccessToken(secret); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("access token after the reset: error = %v, want ErrInvalidAccessToken", err)
+	}
+
+	if err := ResetPassword(token, "third-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("second use: error = %v, want ErrInvalidUserToken", err)
+	}
+	if !CheckPassword(reloadUser(t, user.ID), "new-password") {
+		t.Error("a used token changed the password again")
+	}
+}
+
+func TestPasswordResetExpired(t *testing.T) {
+	useTestDB(t)
+	mailer := useFakeMailer(t)
+	user := createTestUser(t, "old-password")
+
+	if err := SendPasswordResetEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	backdateUserTokens(t, user.ID, TokenPasswordReset, passwordResetTTL+time.Minute)
+
+	if err := ResetPassword(mailer.lastToken(t), "new-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("expired token: error = %v, want ErrInvalidUserToken", err)
+	}
+	if !CheckPassword(reloadUser(t, user.ID), "old-password") {
+		t.Error("an expired token changed the password")
+	}
+}
+
+func TestPasswordResetCooldown(t *testing.T) {
+	useTestDB(t)
+	mailer := useFakeMailer(t)
+	user := createTestUser(t, "old-password")
+
+	if err := SendPasswordResetEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	first := mailer.lastToken(t)
+	if err := SendPasswordResetEmail(user); !errors.Is(err, ErrUserTokenCooldown) {
+		t.Fatalf("second request: error = %v, want ErrUserTokenCooldown", err)
+	}
+	if n := mailer.count(); n != 1 {
+		t.Errorf("%d emails sent, want 1", n)
+	}
+
+	// After the cooldown a new link replaces the first
+	backdateUserTokens(t, user.ID, TokenPasswordReset, userTokenCooldown+time.Second)
+	if err := SendPasswordResetEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	second := mailer.lastToken(t)
+	if err := ResetPassword(first, "new-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("replaced token: error = %v, want ErrInvalidUserToken", err)
+	}
+	if err := ResetPassword(second, "new-password"); err != nil {
+		t.Errorf("latest token: %v", err)
+	}
+
+	// A used link does not hold back the next one
+	if err := SendPasswordResetEmail(user); err != nil {
+		t.Errorf("after using the link: %v", err)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	useTestDB(t)
+	mailer := useFakeMailer(t)
+	user := createTestUser(t, "password")
+
+	if err := SendVerificationEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	token := mailer.lastToken(t)
+
+	if err := ResetPassword(token, "new-password"); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("verification token used for a reset: error = %v, want ErrInvalidUserToken", err)
+	}
+	if err := VerifyEmail(token); err != nil {
+		t.Fatal(err)
+	}
+	if reloadUser(t, user.ID).EmailVerifiedAt == nil {
+		t.Error("address is not verified")
+	}
+	if err := VerifyEmail(token); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("second use: error = %v, want ErrInvalidUserToken", err)
+	}
+}
+
+func TestVerifyEmailExpiredAndCooldown(t *testing.T) {
+	useTestDB(t)
+	mailer := useFakeMailer(t)
+	user := createTestUser(t, "password")
+
+	if err := SendVerificationEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendVerificationEmail(user); !errors.Is(err, ErrUserTokenCooldown) {
+		t.Fatalf("resend: error = %v, want ErrUserTokenCooldown", err)
+	}
+
+	backdateUserTokens(t, user.ID, TokenEmailVerification, emailVerificationTTL+time.Minute)
+	if err := VerifyEmail(mailer.lastToken(t)); !errors.Is(err, ErrInvalidUserToken) {
+		t.Errorf("expired token: error = %v, want ErrInvalidUserToken", err)
+	}
+	if reloadUser(t, user.ID).EmailVerifiedAt != nil {
+		t.Error("an expired token verified the address")
+	}
+
+	// An expired link does not hold back a new one
+	if err := SendVerificationEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEmail(mailer.lastToken(t)); err != nil {
+		t.Errorf("new link: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t, "old-password")
+
+	if err := ChangePassword(user, "", "wrong", "new-password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong current password: error = %v, want ErrWrongPassword", err)
+	}
+	if err := ChangePassword(user, "", "old-password", "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(reloadUser(t, user.ID), "new-password") {
+		t.Error("password was not changed")
+	}
+}
+
+func TestFirstPasswordNeedsEmailedLink(t *testing.T) {
+	useTestDB(t)
+	mailer := useFakeMailer(t)
+	user := createTestUser(t, "") // signed up through single sign-on
+
+	// A session alone cannot add a password
+	if err := ChangePassword(user, "", "", "new-password"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("error = %v, want ErrWrongPassword", err)
+	}
+	if reloadUser(t, user.ID).Password != "" {
+		t.Fatal("a password was set without confirmation")
+	}
+
+	if err := SendPasswordResetEmail(user); err != nil {
+		t.Fatal(err)
+	}
+	if subject := mailer.sent[0].Subject; subject != "Set a password for SkillUp" {
+		t.Errorf("subject = %q, want the password setup email", subject)
+	}
+	if err := ResetPassword(mailer.lastToken(t), "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(reloadUser(t, user.ID), "new-password") {
+		t.Error("the emailed link did not set the password")
+	}
+}
@@ -0,0 +1,408 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"skillup-backend/config"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chunking strategies
+const (
+	ChunkStrategyStructured = "structured" // paragraphs, headings and sentences
+	ChunkStrategyFixed      = "fixed"      // fixed-size character windows
+)
+
+// ChunkOptions configures how document text is split for embedding.
+// It is stored on each Document so the document can be re-chunked identically.
+type ChunkOptions struct {
+	Strategy      string `json:"strategy"`
+	MaxTokens     int    `json:"max_tokens"`
+	OverlapTokens int    `json:"overlap_tokens"`
+}
+
+// TextChunk is a piece of document text with its location in the document
+type TextChunk struct {
+	Text      string
+	CharStart int // character offsets within the joined document text
+	CharEnd   int // exclusive
+	PageStart int // 1-based pages covered; 0 when unknown
+	PageEnd   int
+	Tokens    int
+}
+
+// DefaultChunkOptions returns the chunker settings from config
+func DefaultChunkOptions() ChunkOptions {
+	return ChunkOptions{
+		Strategy:      config.AppConfig.CHUNK_STRATEGY,
+		MaxTokens:     config.AppConfig.CHUNK_MAX_TOKENS,
+		OverlapTokens: config.AppConfig.CHUNK_OVERLAP_TOKENS,
+	}.normalized()
+}
+
+func (o ChunkOptions) normalized() ChunkOptions {
+	if o.Strategy != ChunkStrategyFixed {
+		o.Strategy = ChunkStrategyStructured
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = 400
+	}
+	if o.OverlapTokens < 0 {
+		o.OverlapTokens = 0
+	}
+	if o.OverlapTokens >= o.MaxTokens {
+		o.OverlapTokens = o.MaxTokens / 4
+	}
+	return o
+}
+
+// EstimateTokens approximates the token count of s for typical BPE tokenizers:
+// roughly 4 characters or 0.75 words per token, whichever is larger.
+func EstimateTokens(s string) int {
+	byChars := float64(utf8.RuneCountInString(s)) / 4
+	byWords := float64(len(strings.Fields(s))) * 4 / 3
+	return int(math.Ceil(math.Max(byChars, byWords)))
+}
+
+// ChunkPages chunks the pages of a document (joined as in ExtractedDocument.FullText)
+// and records the character offsets and page range of every chunk
+func ChunkPages(pages []string, opts ChunkOptions) []TextChunk {
+	opts = opts.normalized()
+
+	// pageStarts[i] is the offset of page i+1 in the joined text
+	var runes []rune
+	pageStarts := make([]int, len(pages))
+	for i, p := range pages {
+		pageStarts[i] = len(runes)
+		runes = append(runes, []rune(p)...)
+		runes = append(runes, '\n')
+	}
+
+	var spans []span
+	if opts.Strategy == ChunkStrategyFixed {
+		spans = fixedSpans(runes, opts)
+	} else {
+		spans = packUnits(runes, splitUnits(runes, pageStarts, opts.MaxTokens), opts)
+	}
+
+	chunks := make([]TextChunk, 0, len(spans))
+	for _, sp := range spans {
+		sp = trimSpan(runes, sp)
+		if sp.end <= sp.start {
+			continue
+		}
+		text := string(runes[sp.start:sp.end])
+		chunks = append(chunks, TextChunk{
+			Text:      text,
+			CharStart: sp.start,
+			CharEnd:   sp.end,
+			PageStart: pageAt(pageStarts, sp.start),
+			PageEnd:   pageAt(pageStarts, sp.end-1),
+			Tokens:    EstimateTokens(text),
+		})
+	}
+	return chunks
+}
+
+// span is a [start, end) range of runes
+type span struct {
+	start, end int
+}
+
+// unit is the smallest piece the structured chunker moves around:
+// a heading, a sentence, or a slice of an over-long sentence
+type unit struct {
+	span
+	tokens  int
+	heading bool
+}
+
+// fixedSpans cuts fixed windows of ~MaxTokens with OverlapTokens of overlap
+func fixedSpans(runes []rune, opts ChunkOptions) []span {
+	size := opts.MaxTokens * 4
+	step := size - opts.OverlapTokens*4
+
+	var spans []span
+	for i := 0; i < len(runes); i += step {
+		j := i + size
+		if j > len(runes) {
+			j = len(runes)
+		}
+		spans = append(spans, span{i, j})
+		if j == len(runes) {
+			break
+		}
+	}
+	return spans
+}
+
+var (
+	paragraphBreak  = regexp.MustCompile(`\n[ \t\f\r]*\n`)
+	numberedHeading = regexp.MustCompile(`^(\d+(\.\d+)*\.?|[IVXLC]+\.|Chapter\s+\d+|CHAPTER\s+\d+)\s+\S`)
+)
+
+// splitUnits breaks text into paragraphs, then headings and sentences.
+// Page breaks count as paragraph breaks, so a heading at the top of a page
+// is recognised even though pages are joined with a single newline.
+func splitUnits(runes []rune, pageStarts []int, maxTokens int) []unit {
+	text := string(runes)
+
+	// Regexp offsets are in bytes; map them back to rune offsets
+	byteToRune := make([]int, len(text)+1)
+	r := 0
+	for b := range text {
+		byteToRune[b] = r
+		r++
+	}
+	byteToRune[len(text)] = r
+
+	var breaks []span
+	for _, m := range paragraphBreak.FindAllStringIndex(text, -1) {
+		breaks = append(breaks, span{byteToRune[m[0]], byteToRune[m[1]]})
+	}
+	for _, start := range pageStarts {
+		if start > 0 {
+			breaks = append(breaks, span{start, start})
+		}
+	}
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i].start < breaks[j].start })
+
+	var paras []span
+	prev := 0
+	for _, b := range breaks {
+		if b.start < prev {
+			continue // page start inside a blank-line break
+		}
+		paras = append(paras, span{prev, b.start})
+		prev = b.end
+	}
+	paras = append(paras, span{prev, len(runes)})
+
+	var units []unit
+	for _, p := range paras {
+		p = trimSpan(runes, p)
+		if p.end <= p.start {
+			continue
+		}
+
+		// A heading is either the whole paragraph or its first line
+		head := p
+		for i := p.start; i < p.end; i++ {
+			if runes[i] == '\n' {
+				head.end = i
+				break
+			}
+		}
+		if isHeading(string(runes[head.start:head.end])) {
+			units = append(units, unit{span: head, tokens: EstimateTokens(string(runes[head.start:head.end])), heading: true})
+			p = trimSpan(runes, span{head.end, p.end})
+			if p.end <= p.start {
+				continue
+			}
+		}
+
+		for _, s := range splitSentences(runes, p) {
+			for _, piece := range splitLong(runes, s, maxTokens) {
+				units = append(units, unit{
+					span:   piece,
+					tokens: EstimateTokens(string(runes[piece.start:piece.end])),
+				})
+			}
+		}
+	}
+	return units
+}
+
+// isHeading recognises markdown headings, numbered section titles and short title lines
+func isHeading(block string) bool {
+	if strings.Contains(block, "\n") {
+		return false
+	}
+	line := strings.TrimSpace(block)
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	if utf8.RuneCountInString(line) > 80 || strings.HasSuffix(line, ".") || strings.HasSuffix(line, ",") {
+		return false
+	}
+	if numberedHeading.MatchString(line) {
+		return true
+	}
+
+	// ALL CAPS or Title Case lines of a few words
+	words := strings.Fields(line)
+	if len(words) == 0 || len(words) > 10 {
+		return false
+	}
+	if strings.ToUpper(line) == line && strings.ToLower(line) != line {
+		return true
+	}
+	capitalised := 0
+	for _, w := range words {
+		if r, _ := utf8.DecodeRuneInString(w); unicode.IsUpper(r) {
+			capitalised++
+		}
+	}
+	return capitalised*2 > len(words) && !strings.ContainsAny(line, "?!;")
+}
+
+// abbreviations that end in a period without ending a sentence
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "etc": true, "vs": true, "fig": true, "eq": true,
+	"dr": true, "mr": true, "mrs": true, "ms": true, "prof": true, "al": true,
+	"vol": true, "pp": true, "ch": true, "sec": true,
+}
+
+// splitSentences splits a paragraph at ., ! or ? followed by whitespace
+func splitSentences(runes []rune, p span) []span {
+	var out []span
+	start := p.start
+	for i := p.start; i < p.end; i++ {
+		switch runes[i] {
+		case '.', '!', '?':
+		default:
+			continue
+		}
+
+		// include closing quotes/brackets in the sentence
+		end := i + 1
+		for end < p.end && strings.ContainsRune(`"')]”’`, runes[end]) {
+			end++
+		}
+		if end < p.end && !unicode.IsSpace(runes[end]) {
+			continue
+		}
+		if runes[i] == '.' && isAbbreviation(runes, start, i) {
+			continue
+		}
+
+		out = append(out, span{start, end})
+		start = end
+		i = end - 1
+	}
+	if start < p.end {
+		out = append(out, span{start, p.end})
+	}
+	return out
+}
+
+// isAbbreviation reports whether the word ending at the period at i is an abbreviation or initial
+func isAbbreviation(runes []rune, from, i int) bool {
+	j := i
+	for j > from && !unicode.IsSpace(runes[j-1]) {
+		j--
+	}
+	word := strings.ToLower(string(runes[j:i]))
+	word = strings.TrimLeft(word, `"'([`)
+	return abbreviations[word] || utf8.RuneCountInString(word) == 1
+}
+
+// splitLong cuts a sentence longer than maxTokens at word boundaries
+func splitLong(runes []rune, s span, maxTokens int) []span {
+	if EstimateTokens(string(runes[s.start:s.end])) <= maxTokens {
+		return []span{s}
+	}
+
+	// Count incrementally with the same formula as EstimateTokens
+	var out []span
+	start, lastSpace, words := s.start, -1, 0
+	inWord := false
+	for i := s.start; i < s.end; i++ {
+		if unicode.IsSpace(runes[i]) {
+			lastSpace, inWord = i, false
+		} else if !inWord {
+			words, inWord = words+1, true
+		}
+
+		tokens := math.Max(float64(i+1-start)/4, float64(words)*4/3)
+		if tokens <= float64(maxTokens) {
+			continue
+		}
+
+		cut := i
+		if lastSpace > start {
+			cut = lastSpace
+		}
+		out = append(out, span{start, cut})
+		start, lastSpace, words = cut, -1, 0
+		inWord = false
+		for j := start; j <= i; j++ {
+			if unicode.IsSpace(runes[j]) {
+				inWord = false
+			} else if !inWord {
+				words, inWord = words+1, true
+			}
+		}
+	}
+	if start < s.end {
+		out = append(out, span{start, s.end})
+	}
+	return out
+}
+
+// packUnits greedily groups units into chunks of at most MaxTokens. Headings
+// start a new chunk, and each chunk after a size-based break repeats up to
+// OverlapTokens of trailing sentences from the previous one.
+func packUnits(runes []rune, units []unit, opts ChunkOptions) []span {
+	var spans []span
+	var cur []unit
+	curTokens := 0
+
+	flush := func() {
+		if len(cur) > 0 {
+			spans = append(spans, span{cur[0].start, cur[len(cur)-1].end})
+		}
+	}
+
+	for _, u := range units {
+		switch {
+		case len(cur) == 0:
+		case u.heading && curTokens >= opts.MaxTokens/4:
+			// Start sections on a fresh chunk, unless the current one is tiny
+			flush()
+			cur, curTokens = nil, 0
+		case curTokens+u.tokens > opts.MaxTokens:
+			flush()
+			cur, curTokens = overlapTail(cur, opts.OverlapTokens, opts.MaxTokens-u.tokens)
+		}
+		cur = append(cur, u)
+		curTokens += u.tokens
+	}
+	flush()
+	return spans
+}
+
+// overlapTail returns the trailing non-heading units of prev that fit in
+// overlap tokens (and in room, so the next unit still fits)
+func overlapTail(prev []unit, overlap, room int) ([]unit, int) {
+	if overlap > room {
+		overlap = room
+	}
+	tokens := 0
+	i := len(prev)
+	for i > 0 && !prev[i-1].heading && tokens+prev[i-1].tokens <= overlap {
+		tokens += prev[i-1].tokens
+		i--
+	}
+	return append([]unit(nil), prev[i:]...), tokens
+}
+
+// trimSpan shrinks a span to exclude leading and trailing whitespace
+func trimSpan(runes []rune, s span) span {
+	for s.start < s.end && unicode.IsSpace(runes[s.start]) {
+		s.start++
+	}
+	for s.end > s.start && unicode.IsSpace(runes[s.end-1]) {
+		s.end--
+	}
+	return s
+}
+
+// pageAt returns the 1-based page containing offset
+func pageAt(pageStarts []int, offset int) int {
+	// first page starting after offset, minus one
+	return sort.Search(len(pageStarts), func(i int) bool { return pageStarts[i] > offset })
+}
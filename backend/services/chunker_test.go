@@ -0,0 +1,243 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 2},                     // one word: 4/3 tokens beats 4 chars / 4
+		{"abcdefghijkl", 3},             // by characters
+		{"a b c", 4},                    // 3 words * 4/3 beats 5 chars / 4
+		{strings.Repeat("x", 400), 100}, // one long word: by characters
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.in); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsHeading(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"# Introduction", true},
+		{"## 2.1 Cell structure", true},
+		{"2.1 Cell Structure", true},
+		{"Chapter 3 Photosynthesis", true},
+		{"IV. Results", true},
+		{"CELL DIVISION", true},
+		{"The Krebs Cycle", true},
+		{"The cell divides into two daughter cells.", false},
+		{"Why do cells divide?", false},
+		{"first line\nsecond line", false},
+		{"a lowercase fragment without a period", false},
+	}
+	for _, tt := range tests {
+		if got := isHeading(tt.line); got != tt.want {
+			t.Errorf("isHeading(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"One. Two! Three?", []string{"One.", " Two!", " Three?"}},
+		{"See e.g. Fig. 2 for details. Next.", []string{"See e.g. Fig. 2 for details.", " Next."}},
+		{"J. Watson found it. Then Crick.", []string{"J. Watson found it.", " Then Crick."}},
+		{`He said "stop." Then left.`, []string{`He said "stop."`, " Then left."}},
+		{"Version 1.2 is out", []string{"Version 1.2 is out"}},
+	}
+	for _, tt := range tests {
+		runes := []rune(tt.text)
+		var got []string
+		for _, s := range splitSentences(runes, span{0, len(runes)}) {
+			got = append(got, string(runes[s.start:s.end]))
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("splitSentences(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	sentence := strings.TrimSpace(strings.Repeat("word ", 100))
+	runes := []rune(sentence)
+	pieces := splitLong(runes, span{0, len(runes)}, 20)
+	if len(pieces) < 2 {
+		t.Fatalf("got %d pieces, want the sentence split", len(pieces))
+	}
+	for _, p := range pieces {
+		text := strings.TrimSpace(string(runes[p.start:p.end]))
+		if n := EstimateTokens(text); n > 20 {
+			t.Errorf("piece %q has %d tokens, max 20", text, n)
+		}
+		if strings.HasPrefix(text, "ord") || strings.HasSuffix(text, "wor") {
+			t.Errorf("piece %q cuts a word", text)
+		}
+	}
+	if pieces[0].start != 0 || pieces[len(pieces)-1].end != len(runes) {
+		t.Errorf("pieces %v do not cover the sentence", pieces)
+	}
+}
+
+// sentences returns n distinct sentences of about 10 tokens each
+func sentences(prefix string, n int) string {
+	var parts []string
+	for i := 0; i < n; i++ {
+		parts = append(parts, prefix+" sentence number "+string(rune('a'+i))+" has a few more words.")
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestChunkPagesStructured(t *testing.T) {
+	pages := []string{
+		"# Cells\n\n" + sentences("Cell", 6),
+		"# Energy\n\n" + sentences("Energy", 6),
+	}
+	opts := ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 40, OverlapTokens: 12}
+	chunks := ChunkPages(pages, opts)
+	if len(chunks) < 3 {
+		t.Fatalf("got %d chunks, want the pages split into several", len(chunks))
+	}
+
+	joined := strings.Join(pages, "\n") + "\n"
+	runes := []rune(joined)
+	energyAt := strings.Index(joined, "# Energy")
+	for i, c := range chunks {
+		if got := string(runes[c.CharStart:c.CharEnd]); got != c.Text {
+			t.Errorf("chunk %d offsets give %q, text is %q", i, got, c.Text)
+		}
+		if c.Tokens > opts.MaxTokens {
+			t.Errorf("chunk %d has %d tokens, max %d", i, c.Tokens, opts.MaxTokens)
+		}
+		// Page ranges follow the offsets across the page break
+		wantStart, wantEnd := 1, 1
+		if c.CharStart >= energyAt {
+			wantStart = 2
+		}
+		if c.CharEnd > energyAt {
+			wantEnd = 2
+		}
+		if c.PageStart != wantStart || c.PageEnd != wantEnd {
+			t.Errorf("chunk %d pages %d-%d, want %d-%d", i, c.PageStart, c.PageEnd, wantStart, wantEnd)
+		}
+		// A heading never sits in the middle or end of a chunk
+		if idx := strings.Index(c.Text, "# Energy"); idx > 0 {
+			t.Errorf("chunk %d has the Energy heading at %d, want it to start a chunk: %q", i, idx, c.Text)
+		}
+	}
+
+	var headingChunks int
+	for _, c := range chunks {
+		if strings.HasPrefix(c.Text, "# ") {
+			headingChunks++
+		}
+	}
+	if headingChunks != 2 {
+		t.Errorf("%d chunks start with a heading, want 2", headingChunks)
+	}
+}
+
+func TestChunkPagesOverlap(t *testing.T) {
+	pages := []string{sentences("Plain", 8)}
+	chunks := ChunkPages(pages, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 40, OverlapTokens: 12})
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want several", len(chunks))
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].CharStart >= chunks[i-1].CharEnd {
+			t.Errorf("chunk %d starts at %d, after the previous end %d: no overlap", i, chunks[i].CharStart, chunks[i-1].CharEnd)
+		}
+		// The overlap is whole sentences from the end of the previous chunk
+		repeated := pages[0][chunks[i].CharStart:chunks[i-1].CharEnd]
+		if !strings.HasSuffix(chunks[i-1].Text, repeated) || !strings.HasSuffix(repeated, ".") {
+			t.Errorf("chunk %d overlap %q is not trailing sentences of chunk %d", i, repeated, i-1)
+		}
+		if n := EstimateTokens(repeated); n > 12 {
+			t.Errorf("chunk %d overlap has %d tokens, max 12", i, n)
+		}
+	}
+
+	none := ChunkPages(pages, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 40})
+	for i := 1; i < len(none); i++ {
+		if none[i].CharStart < none[i-1].CharEnd {
+			t.Errorf("without overlap chunk %d overlaps the previous one", i)
+		}
+	}
+}
+
+func TestChunkPagesHeadingNoOverlap(t *testing.T) {
+	// Chunks started by a heading do not repeat the previous section
+	pages := []string{sentences("Intro", 3) + "\n\n# Methods\n\n" + sentences("Method", 2)}
+	chunks := ChunkPages(pages, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 100, OverlapTokens: 50})
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2: %q", len(chunks), chunks)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "# Methods") {
+		t.Errorf("second chunk = %q, want it to start at the heading", chunks[1].Text)
+	}
+}
+
+func TestChunkPagesTinySectionKeepsHeading(t *testing.T) {
+	// A heading right after a short intro does not leave the intro on its own
+	pages := []string{"Short intro.\n\n# Methods\n\n" + sentences("Method", 2)}
+	chunks := ChunkPages(pages, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 100})
+	if len(chunks) != 1 {
+		t.Errorf("got %d chunks, want 1", len(chunks))
+	}
+}
+
+func TestChunkPagesFixed(t *testing.T) {
+	text := strings.Repeat("abcdefghij", 10) // 100 runes
+	chunks := ChunkPages([]string{text}, ChunkOptions{Strategy: ChunkStrategyFixed, MaxTokens: 10, OverlapTokens: 2})
+
+	// windows of 40 runes advancing by 32
+	wantStarts := []int{0, 32, 64}
+	if len(chunks) != len(wantStarts) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(wantStarts))
+	}
+	for i, c := range chunks {
+		if c.CharStart != wantStarts[i] {
+			t.Errorf("chunk %d starts at %d, want %d", i, c.CharStart, wantStarts[i])
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.CharEnd != len(text) {
+		t.Errorf("last chunk ends at %d, want %d", last.CharEnd, len(text))
+	}
+}
+
+func TestChunkOptionsNormalized(t *testing.T) {
+	tests := []struct {
+		in, want ChunkOptions
+	}{
+		{ChunkOptions{}, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 400}},
+		{ChunkOptions{Strategy: "bogus", MaxTokens: 100, OverlapTokens: -5}, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: 100}},
+		{ChunkOptions{Strategy: ChunkStrategyFixed, MaxTokens: 100, OverlapTokens: 100}, ChunkOptions{Strategy: ChunkStrategyFixed, MaxTokens: 100, OverlapTokens: 25}},
+	}
+	for _, tt := range tests {
+		if got := tt.in.normalized(); got != tt.want {
+			t.Errorf("%+v.normalized() = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPageAt(t *testing.T) {
+	starts := []int{0, 10, 25}
+	tests := []struct{ offset, want int }{{0, 1}, {9, 1}, {10, 2}, {24, 2}, {25, 3}, {100, 3}}
+	for _, tt := range tests {
+		if got := pageAt(starts, tt.offset); got != tt.want {
+			t.Errorf("pageAt(%d) = %d, want %d", tt.offset, got, tt.want)
+		}
+	}
+}
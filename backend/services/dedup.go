@@ -0,0 +1,109 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"skillup-backend/db"
+
+	"gorm.io/gorm"
+)
+
+// ContentHash returns the hex SHA-256 of an uploaded file
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FindDuplicateDocument returns the user's existing document with the same
+// content, or nil. Failed documents are ignored so the file can be retried.
+func FindDuplicateDocument(userID, contentHash string) (*db.Document, error) {
+	var doc db.Document
+	err := db.DB.Where("user_id = ? AND content_hash = ? AND processing_status <> ?", userID, contentHash, "failed").
+		Order("upload_date asc").First(&doc).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// reuseIngestion copies the extracted text, pages and chunk embeddings of an
+// already processed document with identical content, chunker settings and
+// embedding model, so an identical file is never extracted or embedded twice.
+// The source may belong to another user: only text and vectors derived from
+// the file are copied, under the new owner, and nothing links the two.
+func reuseIngestion(job *db.IngestionJob) (bool, error) {
+	var doc db.Document
+	if err := db.DB.Select("id", "content_hash").Where("id = ?", job.DocumentID).First(&doc).Error; err != nil {
+		return false, err
+	}
+	if doc.ContentHash == "" {
+		return false, nil
+	}
+
+	opts, err := documentChunkOptions(job.DocumentID)
+	if err != nil {
+		return false, err
+	}
+	params, err := json.Marshal(opts)
+	if err != nil {
+		return false, err
+	}
+
+	var source db.Document
+	err = db.DB.Where("content_hash = ? AND id <> ? AND processing_status = ? AND embedding_model = ? AND chunk_params = ?::jsonb",
+		doc.ContentHash, doc.ID, "processed", EmbeddingModel(), string(params)).
+		Order("upload_date asc").First(&source).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&db.DocumentPage{}, &db.DocumentChunk{}} {
+			if err := tx.Where("document_id = ?", doc.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec(`INSERT INTO document_pages (id, document_id, user_id, page_number, text, char_start, created_at)
+			SELECT gen_random_uuid(), ?, ?, page_number, text, char_start, now()
+			FROM document_pages WHERE document_id = ?`, doc.ID, job.UserID, source.ID).Error; err != nil {
+			return err
+		}
+		res := tx.Exec(`INSERT INTO document_chunks (id, document_id, user_id, chunk_index, chunk_text,
+				page_start, page_end, start_ms, end_ms, char_start, char_end, embedding, created_at)
+			SELECT gen_random_uuid(), ?, ?, chunk_index, chunk_text,
+				page_start, page_end, start_ms, end_ms, char_start, char_end, embedding, now()
+			FROM document_chunks WHERE document_id = ?`, doc.ID, job.UserID, source.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		job.ChunksTotal = int(res.RowsAffected)
+		job.ChunksDone = job.ChunksTotal
+
+		if err := tx.Exec(`UPDATE document_raws r SET text = s.text, cues = s.cues
+			FROM document_raws s WHERE r.document_id = ? AND s.document_id = ?`, doc.ID, source.ID).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"page_count":      source.PageCount,
+			"embedding_model": source.EmbeddingModel,
+		}
+		// Title and author can be edited, so another user's may not be the
+		// file's own metadata
+		if source.UserID == job.UserID {
+			updates["title"], updates["author"] = source.Title, source.Author
+		}
+		return tx.Model(&db.Document{}).Where("id = ?", doc.ID).Updates(updates).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return job.ChunksTotal > 0, nil
+}
@@ -1,101 +1,75 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"log"
 	"skillup-backend/config"
+	"skillup-backend/db"
 
 	"github.com/pgvector/pgvector-go"
 )
 
-// Gemini embedding request structure
-type geminiEmbedReq struct {
-	Content  content `json:"content"`
-	TaskType string  `json:"taskType,omitempty"`
+// EmbeddingModel names the model GetEmbedding currently uses
+func EmbeddingModel() string {
+	return config.AppConfig.EMBED_MODEL
 }
 
-type content struct {
-	Parts []part `json:"parts"`
-}
-
-type part struct {
-	Text string `json:"text"`
-}
-
-// Gemini embedding response structure
-type geminiEmbedResp struct {
-	Embedding struct {
-		Values []float32 `json:"values"`
-	} `json:"embedding"`
-}
-
-// GetEmbedding generates embedding vector using Gemini API
-// taskType: "RETRIEVAL_DOCUMENT" for documents, "RETRIEVAL_QUERY" for queries
-func GetEmbedding(input string) pgvector.Vector {
+// GetEmbedding generates an embedding vector with the configured provider
+// and EMBED_MODEL. Failures are returned as *LLMError.
+func GetEmbedding(input string) (pgvector.Vector, error) {
 	if input == "" {
-		return pgvector.NewVector([]float32{})
-	}
-
-	// Build Gemini API request
-	reqBody := geminiEmbedReq{
-		Content: content{
-			Parts: []part{{Text: input}},
-		},
-		// TaskType is optional - omit for simplicity
+		return pgvector.Vector{}, fmt.Errorf("embedding input is empty")
 	}
 
-	b, err := json.Marshal(reqBody)
+	p := GetProvider()
+	values, err := p.Embed(context.Background(), input)
 	if err != nil {
-		return pgvector.NewVector([]float32{})
+		return pgvector.Vector{}, err
 	}
-
-	// Call Gemini embedding API
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:embedContent?key=%s",
-		config.AppConfig.EMBED_MODEL, config.AppConfig.GEMINI_API_KEY)
-	
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(b))
-	if err != nil {
-		return pgvector.NewVector([]float32{})
+	if len(values) == 0 {
+		return pgvector.Vector{}, newLLMError(p.Name(), ErrLLMBadResponse, "empty embedding returned")
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	return pgvector.NewVector(values), nil
+}
+
+// CheckEmbeddingDimension embeds a probe text and compares its dimension with
+// the document_chunks.embedding column. A column declared with a fixed
+// dimension that differs is fatal, since no chunk could be stored; stored
+// embeddings of another dimension only need their documents reprocessed.
+// An unreachable provider is logged and the check skipped.
+func CheckEmbeddingDimension() {
+	emb, err := GetEmbedding("dimension check")
 	if err != nil {
-		return pgvector.NewVector([]float32{})
+		log.Printf("warning: couldn't check embedding dimension: %v", err)
+		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return pgvector.NewVector([]float32{})
+	dims := len(emb.Slice())
+
+	// atttypmod holds the declared dimension of a vector column, -1 if none
+	var declared int
+	if err := db.DB.Raw(`SELECT atttypmod FROM pg_attribute
+		WHERE attrelid = 'document_chunks'::regclass AND attname = 'embedding'`).Scan(&declared).Error; err != nil {
+		log.Printf("warning: couldn't read embedding column: %v", err)
+		return
 	}
-
-	var out geminiEmbedResp
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return pgvector.NewVector([]float32{})
+	if declared > 0 && declared != dims {
+		log.Fatalf("embedding model %q returns %d dimensions but document_chunks.embedding is vector(%d)",
+			EmbeddingModel(), dims, declared)
 	}
 
-	if len(out.Embedding.Values) == 0 {
-		return pgvector.NewVector([]float32{})
+	var stored []int
+	if err := db.DB.Raw(`SELECT DISTINCT vector_dims(c.embedding) FROM document_chunks c
+		JOIN documents d ON d.id = c.document_id
+		WHERE c.embedding IS NOT NULL AND d.embedding_model = ?`, EmbeddingModel()).Scan(&stored).Error; err != nil {
+		log.Printf("warning: couldn't read stored embeddings: %v", err)
+		return
 	}
-
-	return pgvector.NewVector(out.Embedding.Values)
-}
-
-// ChunkText splits text into chunks for embedding
-func ChunkText(text string) []string {
-	chunkSize := 1500 // characters, not tokens
-	runes := []rune(text)
-	var chunks []string
-
-	for i := 0; i < len(runes); i += chunkSize {
-		j := i + chunkSize
-		if j > len(runes) {
-			j = len(runes)
+	for _, n := range stored {
+		if n != dims {
+			log.Printf("warning: stored embeddings for model %q have %d dimensions, the provider now returns %d; reprocess those documents",
+				EmbeddingModel(), n, dims)
 		}
-		chunks = append(chunks, string(runes[i:j]))
 	}
-	return chunks
 }
-
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"skillup-backend/config"
+	"testing"
+)
+
+func TestProviderEmbed(t *testing.T) {
+	tests := []struct {
+		provider string
+		path     string
+		response string
+	}{
+		{"openai", "/embeddings", `{"data":[{"embedding":[0.5,-1,2]}]}`},
+		{"ollama", "/api/embed", `{"embeddings":[[0.5,-1,2]]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("request to %s, want %s", r.URL.Path, tt.path)
+				}
+				var body struct {
+					Model string `json:"model"`
+					Input string `json:"input"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Model != "embed-test" || body.Input != "hello" {
+					t.Errorf("request body = %+v", body)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			p, err := NewProvider(config.Config{LLM_PROVIDER: tt.provider, LLM_BASE_URL: srv.URL, EMBED_MODEL: "embed-test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Embed(context.Background(), "hello")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []float32{0.5, -1, 2}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Embed = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestProviderEmbedErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	for _, name := range []string{"openai", "ollama"} {
+		p, err := NewProvider(config.Config{LLM_PROVIDER: name, LLM_BASE_URL: srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Embed(context.Background(), "hello"); !errors.Is(err, ErrLLMAuth) {
+			t.Errorf("%s: error = %v, want ErrLLMAuth", name, err)
+		}
+	}
+}
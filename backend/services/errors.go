@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error kinds returned (wrapped in *LLMError) by LLM and embedding calls.
+// Use errors.Is to test for them.
+var (
+	ErrLLMAuth        = errors.New("authentication with the AI provider failed")
+	ErrLLMRateLimited = errors.New("AI provider quota or rate limit exceeded")
+	ErrLLMTimeout     = errors.New("AI provider request timed out")
+	ErrLLMBadResponse = errors.New("AI provider returned an invalid response")
+	ErrLLMBlocked     = errors.New("AI provider blocked the request or response for safety reasons")
+	ErrLLMUnavailable = errors.New("AI provider is unavailable")
+)
+
+// llmTimeout bounds a single non-streaming LLM or embedding request
+const llmTimeout = 2 * time.Minute
+
+var llmHTTPClient = &http.Client{Timeout: llmTimeout}
+
+// llmStreamClient has no overall timeout since streams can legitimately run
+// long; callers bound them with a context instead.
+var llmStreamClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: llmTimeout,
+	},
+}
+
+// LLMError is a failed call to an LLM or embedding backend
+type LLMError struct {
+	Kind       error
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *LLMError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Provider, e.Kind)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+func (e *LLMError) Unwrap() error { return e.Kind }
+
+// LLMErrorKind returns the kind of a failed LLM or embedding call, which is
+// safe to show to users, or "" for any other error. The full error may name
+// provider endpoints or database details and belongs in the logs.
+func LLMErrorKind(err error) string {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr.Kind.Error()
+	}
+	return ""
+}
+
+func newLLMError(provider string, kind error, message string) *LLMError {
+	return &LLMError{Kind: kind, Provider: provider, Message: message}
+}
+
+// errorFromTransport classifies an error returned by http.Client.Do. The
+// message leaves out the request URL, which may carry credentials.
+func errorFromTransport(provider string, err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return newLLMError(provider, ErrLLMTimeout, transportMessage(err))
+	}
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return newLLMError(provider, ErrLLMUnavailable, transportMessage(err))
+}
+
+// transportMessage is the text of a transport error without the *url.Error
+// wrapper that names the method and URL
+func transportMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// streamError classifies an error that interrupted a response stream.
+// Typed errors and errors from the onToken callback pass through unchanged.
+func streamError(provider string, err error) error {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return errorFromTransport(provider, err)
+	}
+	return err
+}
+
+// errorFromStatus classifies a non-200 response from a provider
+func errorFromStatus(provider string, res *http.Response) error {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	var message string
+	if err := json.NewDecoder(res.Body).Decode(&body); err == nil && len(body.Error) > 0 {
+		// Gemini and OpenAI nest {"message": ...}; Ollama uses a plain string
+		var nested struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body.Error, &nested) == nil && nested.Message != "" {
+			message = nested.Message
+		} else {
+			_ = json.Unmarshal(body.Error, &message)
+		}
+	}
+
+	kind := ErrLLMBadResponse
+	switch {
+	case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+		kind = ErrLLMAuth
+	case res.StatusCode == http.StatusTooManyRequests:
+		kind = ErrLLMRateLimited
+	case res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusGatewayTimeout:
+		kind = ErrLLMTimeout
+	case res.StatusCode >= 500:
+		kind = ErrLLMUnavailable
+	case strings.Contains(strings.ToLower(message), "api key"):
+		// Gemini reports invalid keys as 400 INVALID_ARGUMENT
+		kind = ErrLLMAuth
+	}
+
+	return &LLMError{Kind: kind, Provider: provider, StatusCode: res.StatusCode, Message: message}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Supported upload formats
+const (
+	MimePDF      = "application/pdf"
+	MimeDOCX     = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MimeEPUB     = "application/epub+zip"
+	MimeHTML     = "text/html"
+	MimeMarkdown = "text/markdown"
+	MimeText     = "text/plain"
+	MimeSRT      = "application/x-subrip"
+	MimeVTT      = "text/vtt"
+)
+
+// ErrUnsupportedFormat means no extractor handles the uploaded file type
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+// ExtractedDocument is the text and metadata extracted from an uploaded file
+type ExtractedDocument struct {
+	Title     string
+	Author    string
+	PageCount int
+	// Pages[i] is the text of page i+1 ("" when the page has no text). Formats
+	// without pages use chapters or a single page, and Paginated is false.
+	Pages     []string
+	Paginated bool
+	// Cues locate the text of a transcript in time; nil for other formats
+	Cues []TranscriptCue
+}
+
+// FullText joins the pages the same way the stored DocumentRaw.Text is built
+func (d *ExtractedDocument) FullText() string {
+	var sb strings.Builder
+	for _, p := range d.Pages {
+		sb.WriteString(p)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Extractor pulls text and metadata out of one file format
+type Extractor func(data []byte) (*ExtractedDocument, error)
+
+var extractors = map[string]Extractor{
+	MimePDF:      ExtractPDF,
+	MimeDOCX:     ExtractDOCX,
+	MimeEPUB:     ExtractEPUB,
+	MimeHTML:     ExtractHTML,
+	MimeMarkdown: ExtractMarkdown,
+	MimeText:     ExtractText,
+	MimeSRT:      ExtractSRT,
+	MimeVTT:      ExtractVTT,
+}
+
+// RegisterExtractor adds or replaces the extractor for a MIME type
+func RegisterExtractor(mimeType string, e Extractor) {
+	extractors[mimeType] = e
+}
+
+// DetectMimeType sniffs the content of an upload and returns the most specific
+// supported MIME type, or "" if the format is not supported. The filename
+// only refines plain text: Markdown has no signature of its own and subtitle
+// files are only recognized by content when they start in the usual way.
+func DetectMimeType(data []byte, filename string) string {
+	for m := mimetype.Detect(data); m != nil; m = m.Parent() {
+		mt := strings.SplitN(m.String(), ";", 2)[0]
+		if mt == MimeText {
+			switch strings.ToLower(filepath.Ext(filename)) {
+			case ".md", ".markdown":
+				return MimeMarkdown
+			case ".srt":
+				return MimeSRT
+			case ".vtt":
+				return MimeVTT
+			}
+		}
+		if _, ok := extractors[mt]; ok {
+			return mt
+		}
+	}
+	return ""
+}
+
+// ExtractDocument runs the extractor registered for mimeType. Documents
+// uploaded before the type was recorded have an empty type and are PDFs.
+func ExtractDocument(mimeType string, data []byte) (*ExtractedDocument, error) {
+	if mimeType == "" {
+		mimeType = MimePDF
+	}
+	extract, ok := extractors[mimeType]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+	return extract(data)
+}
+
+// ExtractText reads a plain text file as a single page
+func ExtractText(data []byte) (*ExtractedDocument, error) {
+	text := normalizeNewlines(strings.TrimPrefix(toValidUTF8(data), "\ufeff"))
+	return &ExtractedDocument{Pages: []string{text}, PageCount: 1}, nil
+}
+
+// ExtractMarkdown reads a Markdown file as a single page. The markup is kept:
+// "#" headings are what the structured chunker splits sections on. YAML front
+// matter is dropped and its title used if present.
+func ExtractMarkdown(data []byte) (*ExtractedDocument, error) {
+	text := normalizeNewlines(strings.TrimPrefix(toValidUTF8(data), "\ufeff"))
+	doc := &ExtractedDocument{PageCount: 1}
+
+	if strings.HasPrefix(text, "---\n") {
+		if end := strings.Index(text[4:], "\n---"); end >= 0 {
+			for _, line := range strings.Split(text[4:4+end], "\n") {
+				key, value, ok := strings.Cut(line, ":")
+				if !ok {
+					continue
+				}
+				value = strings.Trim(strings.TrimSpace(value), `"'`)
+				switch strings.TrimSpace(strings.ToLower(key)) {
+				case "title":
+					doc.Title = value
+				case "author":
+					doc.Author = value
+				}
+			}
+			text = strings.TrimLeft(text[4+end+4:], "\n")
+		}
+	}
+
+	if doc.Title == "" {
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "# ") {
+				doc.Title = strings.TrimSpace(line[2:])
+				break
+			}
+		}
+	}
+
+	doc.Pages = []string{text}
+	return doc, nil
+}
+
+func toValidUTF8(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
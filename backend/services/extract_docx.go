@@ -0,0 +1,178 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxArchiveEntry bounds how much of one zip entry is read (zip bomb guard)
+const maxArchiveEntry = 64 << 20
+
+// ExtractDOCX reads a Word document. Paragraphs become lines separated by blank
+// lines, Heading styles become "#" headings, and the page breaks Word recorded
+// when the file was last saved split the text into pages.
+func ExtractDOCX(data []byte) (*ExtractedDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("malformed DOCX: %w", err)
+	}
+
+	body, err := readZipEntry(zr, "word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("malformed DOCX: %w", err)
+	}
+
+	pages, err := docxPages(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed DOCX: %w", err)
+	}
+
+	doc := &ExtractedDocument{Pages: pages, PageCount: len(pages), Paginated: len(pages) > 1}
+	if core, err := readZipEntry(zr, "docProps/core.xml"); err == nil {
+		doc.Title, doc.Author = coreProperties(core)
+	}
+	return doc, nil
+}
+
+// docxPages walks the WordprocessingML body collecting paragraph text
+func docxPages(body []byte) ([]string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+
+	var pages []string
+	var page, para strings.Builder
+	var inText bool
+	heading := 0
+
+	endPage := func() {
+		pages = append(pages, strings.TrimSpace(page.String()))
+		page.Reset()
+	}
+	endPara := func() {
+		text := strings.TrimSpace(para.String())
+		para.Reset()
+		if text == "" {
+			return
+		}
+		if heading > 0 {
+			text = strings.Repeat("#", heading) + " " + text
+		}
+		page.WriteString(text)
+		page.WriteString("\n\n")
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				heading = 0
+			case "pStyle":
+				heading = headingLevel(xmlAttr(t, "val"))
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteString("\t")
+			case "br", "cr":
+				if xmlAttr(t, "type") == "page" {
+					endPara()
+					endPage()
+				} else {
+					para.WriteString("\n")
+				}
+			case "lastRenderedPageBreak":
+				// Word's own pagination; break the page mid-paragraph if needed
+				if para.Len() > 0 || page.Len() > 0 {
+					endPara()
+					endPage()
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				endPara()
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	endPara()
+	if page.Len() > 0 || len(pages) == 0 {
+		endPage()
+	}
+	return pages, nil
+}
+
+// headingLevel maps paragraph styles like "Heading2" or "Title" to a heading level
+func headingLevel(style string) int {
+	s := strings.ToLower(style)
+	switch {
+	case s == "title":
+		return 1
+	case strings.HasPrefix(s, "heading"):
+		n := 0
+		fmt.Sscanf(s[len("heading"):], "%d", &n)
+		if n >= 1 && n <= 6 {
+			return n
+		}
+	}
+	return 0
+}
+
+// coreProperties reads dc:title and dc:creator from docProps/core.xml
+func coreProperties(core []byte) (title, author string) {
+	var props struct {
+		Title   string `xml:"title"`
+		Creator string `xml:"creator"`
+	}
+	if xml.Unmarshal(core, &props) == nil {
+		title, author = strings.TrimSpace(props.Title), strings.TrimSpace(props.Creator)
+	}
+	return title, author
+}
+
+func readZipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxArchiveEntry+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxArchiveEntry {
+			return nil, fmt.Errorf("%s is too large", name)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+func xmlAttr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractEPUB reads an EPUB book with one page per chapter in reading order.
+// Chapters are not print pages, so the document is not paginated.
+func ExtractEPUB(data []byte) (*ExtractedDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("malformed EPUB: %w", err)
+	}
+
+	container, err := readZipEntry(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil, fmt.Errorf("malformed EPUB: %w", err)
+	}
+	var c struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &c); err != nil || len(c.Rootfiles) == 0 {
+		return nil, fmt.Errorf("malformed EPUB: no package document")
+	}
+	opfPath := c.Rootfiles[0].FullPath
+
+	opf, err := readZipEntry(zr, opfPath)
+	if err != nil {
+		return nil, fmt.Errorf("malformed EPUB: %w", err)
+	}
+	var pkg struct {
+		Title   []string `xml:"metadata>title"`
+		Creator []string `xml:"metadata>creator"`
+		Items   []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(opf, &pkg); err != nil {
+		return nil, fmt.Errorf("malformed EPUB: %w", err)
+	}
+
+	hrefs := make(map[string]string, len(pkg.Items))
+	for _, item := range pkg.Items {
+		if strings.Contains(item.MediaType, "html") {
+			hrefs[item.ID] = item.Href
+		}
+	}
+
+	doc := &ExtractedDocument{}
+	if len(pkg.Title) > 0 {
+		doc.Title = strings.TrimSpace(pkg.Title[0])
+	}
+	if len(pkg.Creator) > 0 {
+		doc.Author = strings.TrimSpace(pkg.Creator[0])
+	}
+
+	base := path.Dir(opfPath)
+	for _, ref := range pkg.Spine {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapter, err := readZipEntry(zr, path.Join(base, href))
+		if err != nil {
+			continue
+		}
+		root, err := html.Parse(bytes.NewReader(chapter))
+		if err != nil {
+			continue
+		}
+		if text, _ := htmlText(root); text != "" {
+			doc.Pages = append(doc.Pages, text)
+		}
+	}
+	if len(doc.Pages) == 0 {
+		return nil, fmt.Errorf("malformed EPUB: no readable chapters")
+	}
+	doc.PageCount = len(doc.Pages)
+	return doc, nil
+}
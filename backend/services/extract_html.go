@@ -0,0 +1,159 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ExtractHTML reads an HTML page as a single page of text
+func ExtractHTML(data []byte) (*ExtractedDocument, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("malformed HTML: %w", err)
+	}
+
+	text, title := htmlText(root)
+	doc := &ExtractedDocument{Title: title, PageCount: 1, Pages: []string{text}}
+	if author := htmlMeta(root, "author"); author != "" {
+		doc.Author = author
+	}
+	return doc, nil
+}
+
+// htmlText renders the visible text of a document with paragraphs separated
+// by blank lines and headings marked with "#", so the chunker sees structure
+func htmlText(root *html.Node) (text, title string) {
+	var sb strings.Builder
+	var walk func(n *html.Node, pre bool)
+
+	breakBlock := func() {
+		s := sb.String()
+		if s != "" && !strings.HasSuffix(s, "\n\n") {
+			if strings.HasSuffix(s, "\n") {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+	}
+
+	walk = func(n *html.Node, pre bool) {
+		switch n.Type {
+		case html.TextNode:
+			if pre {
+				sb.WriteString(n.Data)
+				return
+			}
+			t := strings.Join(strings.Fields(n.Data), " ")
+			if t == "" {
+				return
+			}
+			// keep the space between inline elements
+			if s := sb.String(); s != "" && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, " ") &&
+				(n.Data[0] == ' ' || n.Data[0] == '\n' || n.Data[0] == '\t') {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(t)
+			if last := n.Data[len(n.Data)-1]; last == ' ' || last == '\n' || last == '\t' {
+				sb.WriteString(" ")
+			}
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg:
+				return
+			case atom.Title:
+				if title == "" && n.FirstChild != nil {
+					title = strings.TrimSpace(n.FirstChild.Data)
+				}
+				return
+			case atom.Br:
+				sb.WriteString("\n")
+				return
+			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+				breakBlock()
+				sb.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+			case atom.Li:
+				if s := sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+					sb.WriteString("\n")
+				}
+				sb.WriteString("- ")
+			case atom.Pre:
+				breakBlock()
+				pre = true
+			default:
+				if htmlBlocks[n.DataAtom] {
+					breakBlock()
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, pre)
+		}
+
+		if n.Type == html.ElementNode && (htmlBlocks[n.DataAtom] || n.DataAtom == atom.Pre || isHeadingAtom(n.DataAtom)) {
+			breakBlock()
+		}
+	}
+	walk(root, false)
+
+	// trim the trailing spaces left at line ends by inline text
+	lines := strings.Split(sb.String(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), title
+}
+
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true,
+	atom.Header: true, atom.Footer: true, atom.Main: true, atom.Aside: true, atom.Nav: true,
+	atom.Blockquote: true, atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Tr: true, atom.Figure: true, atom.Figcaption: true, atom.Hr: true,
+	atom.Body: true,
+}
+
+func isHeadingAtom(a atom.Atom) bool {
+	switch a {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return true
+	}
+	return false
+}
+
+// htmlMeta returns the content of <meta name="...">
+func htmlMeta(root *html.Node, name string) string {
+	var found string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if found != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Meta {
+			var isName bool
+			var content string
+			for _, a := range n.Attr {
+				switch strings.ToLower(a.Key) {
+				case "name":
+					isName = strings.EqualFold(a.Val, name)
+				case "content":
+					content = a.Val
+				}
+			}
+			if isName {
+				found = strings.TrimSpace(content)
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	return found
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// cueParagraphGapMs is the pause between cues that starts a new paragraph
+const cueParagraphGapMs = 2000
+
+// TranscriptCue is one subtitle cue and where its text sits in the document
+type TranscriptCue struct {
+	StartMs   int `json:"start_ms"`
+	EndMs     int `json:"end_ms"`
+	CharStart int `json:"char_start"` // character offsets within DocumentRaw.Text
+	CharEnd   int `json:"char_end"`   // exclusive
+}
+
+var (
+	cueTag      = regexp.MustCompile(`<[^>]*>`)
+	cueVoice    = regexp.MustCompile(`^<v(?:\.[^ >]*)?\s+([^>]+)>`)
+	cueOverride = regexp.MustCompile(`\{\\[^}]*\}`) // SSA-style positioning in SRT
+)
+
+// ExtractSRT reads a SubRip subtitle file as a timed transcript
+func ExtractSRT(data []byte) (*ExtractedDocument, error) {
+	return extractTranscript(data)
+}
+
+// ExtractVTT reads a WebVTT subtitle file as a timed transcript. A title after
+// the WEBVTT header line is used as the document title.
+func ExtractVTT(data []byte) (*ExtractedDocument, error) {
+	doc, err := extractTranscript(data)
+	if err != nil {
+		return nil, err
+	}
+	text := normalizeNewlines(strings.TrimPrefix(toValidUTF8(data), "\ufeff"))
+	header, _, _ := strings.Cut(text, "\n")
+	if rest, ok := strings.CutPrefix(header, "WEBVTT"); ok {
+		doc.Title = strings.TrimLeft(strings.TrimSpace(rest), "- ")
+	}
+	return doc, nil
+}
+
+// extractTranscript turns subtitle cues into a single page of text, one cue
+// per line with a blank line at longer pauses. Rolling captions that repeat
+// the previous cue are merged into it.
+func extractTranscript(data []byte) (*ExtractedDocument, error) {
+	text := normalizeNewlines(strings.TrimPrefix(toValidUTF8(data), "\ufeff"))
+
+	var sb strings.Builder
+	var cues []TranscriptCue
+	var last string
+	offset := 0
+
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		timing := -1
+		for i, l := range lines {
+			if strings.Contains(l, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue // header, NOTE, STYLE or stray text
+		}
+		start, end, err := parseCueTiming(lines[timing])
+		if err != nil {
+			return nil, fmt.Errorf("malformed subtitles: %w", err)
+		}
+
+		cueText := cleanCueText(lines[timing+1:])
+		if cueText == "" {
+			continue
+		}
+		if cueText == last && len(cues) > 0 {
+			if end > cues[len(cues)-1].EndMs {
+				cues[len(cues)-1].EndMs = end
+			}
+			continue
+		}
+
+		if len(cues) > 0 {
+			sep := "\n"
+			if start-cues[len(cues)-1].EndMs >= cueParagraphGapMs {
+				sep = "\n\n"
+			}
+			sb.WriteString(sep)
+			offset += len(sep)
+		}
+		n := utf8.RuneCountInString(cueText)
+		cues = append(cues, TranscriptCue{StartMs: start, EndMs: end, CharStart: offset, CharEnd: offset + n})
+		sb.WriteString(cueText)
+		offset += n
+		last = cueText
+	}
+
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("malformed subtitles: no cues found")
+	}
+	return &ExtractedDocument{Pages: []string{sb.String()}, PageCount: 1, Cues: cues}, nil
+}
+
+// cleanCueText joins a cue's lines and strips markup, keeping WebVTT voice
+// spans as a "Speaker: " prefix
+func cleanCueText(lines []string) string {
+	var parts []string
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if m := cueVoice.FindStringSubmatch(l); m != nil {
+			l = strings.TrimSpace(m[1]) + ": " + l[len(m[0]):]
+		}
+		l = cueOverride.ReplaceAllString(cueTag.ReplaceAllString(l, ""), "")
+		l = strings.Join(strings.Fields(html.UnescapeString(l)), " ")
+		if l != "" {
+			parts = append(parts, l)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseCueTiming reads "00:01:02,500 --> 00:01:04,000" (SRT) or
+// "01:02.500 --> 01:04.000 align:start" (WebVTT)
+func parseCueTiming(line string) (start, end int, err error) {
+	from, to, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(to)
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("bad cue timing %q", line)
+	}
+	if start, err = parseTimestamp(strings.TrimSpace(from)); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseTimestamp(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		end = start
+	}
+	return start, end, nil
+}
+
+// parseTimestamp converts [hh:]mm:ss[.,]mmm to milliseconds
+func parseTimestamp(ts string) (int, error) {
+	parts := strings.Split(strings.Replace(ts, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("bad timestamp %q", ts)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad timestamp %q", ts)
+	}
+	ms := int(seconds*1000 + 0.5)
+	unit := 60 * 1000
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return 0, fmt.Errorf("bad timestamp %q", ts)
+		}
+		ms += n * unit
+		unit *= 60
+	}
+	return ms, nil
+}
+
+// cueSpan returns the time range of the cues overlapping [charStart, charEnd);
+// nil when there are no cues
+func cueSpan(cues []TranscriptCue, charStart, charEnd int) (start, end *int) {
+	first := sort.Search(len(cues), func(i int) bool { return cues[i].CharEnd > charStart })
+	if first == len(cues) {
+		return nil, nil
+	}
+	last := first
+	for last+1 < len(cues) && cues[last+1].CharStart < charEnd {
+		last++
+	}
+	s, e := cues[first].StartMs, cues[last].EndMs
+	return &s, &e
+}
+
+// timeLabel formats a time range as "12:03-13:10"; empty when unknown
+func timeLabel(startMs, endMs *int) string {
+	switch {
+	case startMs != nil && endMs != nil && *endMs/1000 != *startMs/1000:
+		return formatTimestamp(*startMs) + "-" + formatTimestamp(*endMs)
+	case startMs != nil:
+		return formatTimestamp(*startMs)
+	}
+	return ""
+}
+
+// formatTimestamp formats milliseconds as m:ss or h:mm:ss
+func formatTimestamp(ms int) string {
+	s := ms / 1000
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "00:00:01,000", want: 1000},               // SRT
+		{in: "00:01:02,500", want: 62500},              // SRT
+		{in: "01:02:03,004", want: 3723004},            // SRT with hours
+		{in: "12:00:00,000", want: 12 * 3600 * 1000},   // SRT, many hours
+		{in: "01:02.500", want: 62500},                 // WebVTT without hours
+		{in: "1:00:03.500", want: 3603500},             // WebVTT with a one-digit hour
+		{in: "00:00:59.9996", want: 60000},             // rounds to the millisecond
+		{in: "00:05", want: 5000},                      // no fraction
+		{in: "100:00:00.000", want: 100 * 3600 * 1000}, // long recordings
+		{in: "00:00:01.5", want: 1500},                 // short fraction
+		{in: "5", wantErr: true},                       // seconds only
+		{in: "1:02:03:04.000", wantErr: true},          // too many fields
+		{in: "aa:01.000", wantErr: true},               // not a number
+		{in: "00:01,000,5", wantErr: true},             // two commas
+		{in: "", wantErr: true},                        // empty
+	}
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimestamp(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("parseTimestamp(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseCueTiming(t *testing.T) {
+	tests := []struct {
+		line       string
+		start, end int
+		wantErr    bool
+	}{
+		{line: "00:01:02,500 --> 00:01:04,000", start: 62500, end: 64000},
+		{line: "01:02.500 --> 01:04.000 align:start position:10%", start: 62500, end: 64000},
+		{line: "00:00:05,000 --> 00:00:04,000", start: 5000, end: 5000}, // end before start
+		{line: "00:00:05,000 -->", wantErr: true},
+		{line: "soon --> later", wantErr: true},
+	}
+	for _, tt := range tests {
+		start, end, err := parseCueTiming(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCueTiming(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (start != tt.start || end != tt.end) {
+			t.Errorf("parseCueTiming(%q) = %d, %d, want %d, %d", tt.line, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestExtractTranscript(t *testing.T) {
+	tests := []struct {
+		name      string
+		vtt       bool
+		data      string
+		wantText  string
+		wantCues  []TranscriptCue
+		wantTitle string
+	}{
+		{
+			name: "srt with hours, rolling captions and a pause",
+			data: "1\n00:00:01,000 --> 00:00:02,500\nHello <i>world</i>\n\n" +
+				"2\n00:00:02,600 --> 00:00:04,000\nHello <i>world</i>\n\n" +
+				"3\n00:00:04,500 --> 00:00:05,000\n{\\an8}Next  line\n\n" +
+				"4\n01:00:10,000 --> 01:00:12,000\nAn hour\nlater\n",
+			wantText: "Hello world\nNext line\n\nAn hour later",
+			wantCues: []TranscriptCue{
+				{StartMs: 1000, EndMs: 4000, CharStart: 0, CharEnd: 11},
+				{StartMs: 4500, EndMs: 5000, CharStart: 12, CharEnd: 21},
+				{StartMs: 3610000, EndMs: 3612000, CharStart: 23, CharEnd: 36},
+			},
+		},
+		{
+			name: "vtt with BOM, CRLF, notes, voices and a title",
+			vtt:  true,
+			data: "\ufeffWEBVTT - Lecture 1\r\n\r\nNOTE a comment\r\n\r\n" +
+				"00:01.000 --> 00:03.000 align:start\r\n<v Dr. Smith>Welcome &amp; hello\r\n\r\n" +
+				"cue-2\r\n1:00:03.500 --> 1:00:05.000\r\nÉtude\r\n",
+			wantText: "Dr. Smith: Welcome & hello\n\nÉtude",
+			wantCues: []TranscriptCue{
+				{StartMs: 1000, EndMs: 3000, CharStart: 0, CharEnd: 26},
+				{StartMs: 3603500, EndMs: 3605000, CharStart: 28, CharEnd: 33},
+			},
+			wantTitle: "Lecture 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extract := ExtractSRT
+			if tt.vtt {
+				extract = ExtractVTT
+			}
+			doc, err := extract([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(doc.Pages) != 1 || doc.Pages[0] != tt.wantText {
+				t.Errorf("pages = %q, want [%q]", doc.Pages, tt.wantText)
+			}
+			if !reflect.DeepEqual(doc.Cues, tt.wantCues) {
+				t.Errorf("cues = %+v, want %+v", doc.Cues, tt.wantCues)
+			}
+			if doc.Title != tt.wantTitle {
+				t.Errorf("title = %q, want %q", doc.Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestExtractTranscriptErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"WEBVTT\n\nNOTE only a note\n",
+		"1\n00:00:01,000 --> \nNo end time\n",
+		"1\n00:00:01,000 --> 00:00:02,000\n<i></i>\n", // no text
+	} {
+		if _, err := extractTranscript([]byte(data)); err == nil {
+			t.Errorf("extractTranscript(%q) succeeded, want an error", data)
+		}
+	}
+}
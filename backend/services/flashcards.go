@@ -0,0 +1,151 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"skillup-backend/db"
+	"strings"
+	"time"
+)
+
+const (
+	// minEaseFactor is the SM-2 lower bound; cards never get harder than this
+	minEaseFactor = 1.3
+	// maxGeneratedFlashcards caps one generation request
+	maxGeneratedFlashcards = 50
+)
+
+// GeneratedFlashcard is a card proposed by the LLM, before it is saved to a deck
+type GeneratedFlashcard struct {
+	Front  string
+	Back   string
+	Source *QuestionSource
+}
+
+// ScheduleReview applies an SM-2 review with a 0-5 grade to the card.
+// Grades below 3 restart the repetition sequence; the ease factor is
+// adjusted on every review and never drops below 1.3.
+func ScheduleReview(card *db.Flashcard, grade int, now time.Time) error {
+	if grade < 0 || grade > 5 {
+		return fmt.Errorf("grade must be between 0 and 5")
+	}
+
+	if card.EaseFactor == 0 {
+		card.EaseFactor = 2.5
+	}
+
+	if grade < 3 {
+		card.Repetitions = 0
+		card.IntervalDays = 1
+	} else {
+		switch card.Repetitions {
+		case 0:
+			card.IntervalDays = 1
+		case 1:
+			card.IntervalDays = 6
+		default:
+			card.IntervalDays = int(math.Round(float64(card.IntervalDays) * card.EaseFactor))
+		}
+		card.Repetitions++
+	}
+
+	q := float64(5 - grade)
+	card.EaseFactor += 0.1 - q*(0.08+q*0.02)
+	if card.EaseFactor < minEaseFactor {
+		card.EaseFactor = minEaseFactor
+	}
+
+	card.DueAt = now.AddDate(0, 0, card.IntervalDays)
+	card.LastReviewedAt = &now
+	return nil
+}
+
+// GenerateFlashcards writes question/answer cards from selected document chunks
+// (see SelectQuizChunks). Cards whose front matches an existing one are dropped.
+func GenerateFlashcards(chunks []QuizChunk, existing []string, count int) ([]GeneratedFlashcard, error) {
+	if len(chunks) == 0 {
+		return nil, ErrNoQuizMaterial
+	}
+	if count <= 0 {
+		count = 10
+	}
+	if count > maxGeneratedFlashcards {
+		count = maxGeneratedFlashcards
+	}
+
+	var excerpts []string
+	for i, c := range chunks {
+		label := fmt.Sprintf("[C%d]", i+1)
+		if loc := locationLabel(c.PageStart, c.PageEnd, c.StartMs, c.EndMs); loc != "" {
+			label += " (" + loc + ")"
+		}
+		excerpts = append(excerpts, label+"\n"+c.Text)
+	}
+
+	prompt := fmt.Sprintf(`You are creating study flashcards. Write %d flashcards from the following excerpts of a document. Spread the cards across the excerpts.
+
+Excerpts:
+%s
+
+Return ONLY a valid JSON array with this exact structure:
+[
+  {"front": "A question or term", "back": "The answer or definition", "source": "C1"}
+]
+
+Requirements:
+- Each card tests one fact, definition or concept
+- The front must make sense on its own; keep the back short
+- source is the tag of the excerpt the card is based on
+- Return ONLY the JSON array, no other text`, count, strings.Join(excerpts, "\n\n"))
+
+	response, err := LLM(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate flashcards: %w", err)
+	}
+
+	startIdx := strings.Index(response, "[")
+	endIdx := strings.LastIndex(response, "]")
+	if startIdx == -1 || endIdx == -1 || endIdx < startIdx {
+		return nil, newLLMError(GetProvider().Name(), ErrLLMBadResponse, "no JSON array in flashcard response")
+	}
+
+	var generated []struct {
+		Front  string `json:"front"`
+		Back   string `json:"back"`
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal([]byte(response[startIdx:endIdx+1]), &generated); err != nil {
+		return nil, newLLMError(GetProvider().Name(), ErrLLMBadResponse, "failed to parse flashcards: "+err.Error())
+	}
+
+	seen := map[string]bool{}
+	for _, front := range existing {
+		seen[normalizeText(front)] = true
+	}
+
+	cards := make([]GeneratedFlashcard, 0, len(generated))
+	for _, g := range generated {
+		key := normalizeText(g.Front)
+		if key == "" || strings.TrimSpace(g.Back) == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		card := GeneratedFlashcard{Front: strings.TrimSpace(g.Front), Back: strings.TrimSpace(g.Back)}
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSpace(g.Source), "C%d", &n); err == nil && n >= 1 && n <= len(chunks) {
+			c := chunks[n-1]
+			card.Source = &QuestionSource{ChunkID: c.ChunkID, PageStart: c.PageStart, PageEnd: c.PageEnd, StartMs: c.StartMs, EndMs: c.EndMs, Snippet: snippet(c.Text)}
+		}
+		cards = append(cards, card)
+		if len(cards) == count {
+			break
+		}
+	}
+
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("no flashcards generated")
+	}
+	return cards, nil
+}
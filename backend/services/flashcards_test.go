@@ -0,0 +1,93 @@
+package services
+
+import (
+	"math"
+	"skillup-backend/db"
+	"testing"
+	"time"
+)
+
+func TestScheduleReview(t *testing.T) {
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		card       db.Flashcard
+		grade      int
+		wantReps   int
+		wantDays   int
+		wantEase   float64
+		wantErrors bool
+	}{
+		// A new card (ease 0 means 2.5) graded 0-5
+		{name: "new, grade 5", grade: 5, wantReps: 1, wantDays: 1, wantEase: 2.6},
+		{name: "new, grade 4", grade: 4, wantReps: 1, wantDays: 1, wantEase: 2.5},
+		{name: "new, grade 3", grade: 3, wantReps: 1, wantDays: 1, wantEase: 2.36},
+		{name: "new, grade 2", grade: 2, wantReps: 0, wantDays: 1, wantEase: 2.18},
+		{name: "new, grade 1", grade: 1, wantReps: 0, wantDays: 1, wantEase: 1.96},
+		{name: "new, grade 0", grade: 0, wantReps: 0, wantDays: 1, wantEase: 1.7},
+
+		{name: "second success is six days", card: db.Flashcard{Repetitions: 1, IntervalDays: 1, EaseFactor: 2.5},
+			grade: 4, wantReps: 2, wantDays: 6, wantEase: 2.5},
+		{name: "later success multiplies by ease", card: db.Flashcard{Repetitions: 2, IntervalDays: 6, EaseFactor: 2.5},
+			grade: 5, wantReps: 3, wantDays: 15, wantEase: 2.6},
+		{name: "interval uses ease before the update", card: db.Flashcard{Repetitions: 3, IntervalDays: 15, EaseFactor: 2.0},
+			grade: 3, wantReps: 4, wantDays: 30, wantEase: 1.86},
+		{name: "lapse resets repetitions and interval", card: db.Flashcard{Repetitions: 5, IntervalDays: 40, EaseFactor: 2.2},
+			grade: 2, wantReps: 0, wantDays: 1, wantEase: 1.88},
+		{name: "ease never drops below 1.3", card: db.Flashcard{Repetitions: 2, IntervalDays: 6, EaseFactor: 1.4},
+			grade: 0, wantReps: 0, wantDays: 1, wantEase: minEaseFactor},
+
+		{name: "grade above 5", grade: 6, wantErrors: true},
+		{name: "negative grade", grade: -1, wantErrors: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := tt.card
+			err := ScheduleReview(&card, tt.grade, now)
+			if tt.wantErrors {
+				if err == nil {
+					t.Fatal("want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if card.Repetitions != tt.wantReps {
+				t.Errorf("repetitions = %d, want %d", card.Repetitions, tt.wantReps)
+			}
+			if card.IntervalDays != tt.wantDays {
+				t.Errorf("interval = %d days, want %d", card.IntervalDays, tt.wantDays)
+			}
+			if math.Abs(card.EaseFactor-tt.wantEase) > 1e-9 {
+				t.Errorf("ease = %v, want %v", card.EaseFactor, tt.wantEase)
+			}
+			if want := now.AddDate(0, 0, tt.wantDays); !card.DueAt.Equal(want) {
+				t.Errorf("due %v, want %v", card.DueAt, want)
+			}
+			if card.LastReviewedAt == nil || !card.LastReviewedAt.Equal(now) {
+				t.Errorf("last reviewed = %v, want %v", card.LastReviewedAt, now)
+			}
+		})
+	}
+}
+
+func TestScheduleReviewSequence(t *testing.T) {
+	// Perfect recall: 1, 6, then growing by the (rising) ease factor
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	card := db.Flashcard{}
+	var intervals []int
+	for i := 0; i < 5; i++ {
+		if err := ScheduleReview(&card, 5, now); err != nil {
+			t.Fatal(err)
+		}
+		intervals = append(intervals, card.IntervalDays)
+		now = card.DueAt
+	}
+	want := []int{1, 6, 16, 45, 131}
+	for i := range want {
+		if intervals[i] != want[i] {
+			t.Fatalf("intervals = %v, want %v", intervals, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"fmt"
+	"skillup-backend/db"
+)
+
+// Goal statuses
+const (
+	GoalActive    = "active"
+	GoalCompleted = "completed"
+	GoalArchived  = "archived"
+)
+
+// goalTransitions lists the statuses each status may move to
+var goalTransitions = map[string][]string{
+	GoalActive:    {GoalCompleted, GoalArchived},
+	GoalCompleted: {GoalActive, GoalArchived}, // reopen or put away
+	GoalArchived:  {GoalActive},
+}
+
+// GoalProgress is a goal's computed completion
+type GoalProgress struct {
+	Percent          float64  `json:"percent"`
+	TopicsCompleted  int      `json:"topics_completed"`
+	TopicsTotal      int      `json:"topics_total"`
+	QuizAverage      *float64 `json:"quiz_average"` // mean best score over quizzed documents
+	DocumentsQuizzed int      `json:"documents_quizzed"`
+	DocumentsTotal   int      `json:"documents_total"`
+}
+
+// ValidGoalStatus reports whether status is a known goal status
+func ValidGoalStatus(status string) bool {
+	_, ok := goalTransitions[status]
+	return ok
+}
+
+// ValidateGoalTransition checks that a goal may move from one status to another
+func ValidateGoalTransition(from, to string) error {
+	if !ValidGoalStatus(to) {
+		return fmt.Errorf("status must be one of active, completed or archived")
+	}
+	if from == to {
+		return nil
+	}
+	for _, s := range goalTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change goal status from %s to %s", from, to)
+}
+
+// ComputeGoalProgress combines the share of completed topics with quiz
+// results on the goal's documents, where each document counts with its best
+// quiz score (0 until quizzed). Completed goals are always 100%.
+func ComputeGoalProgress(goal *db.Goal) (GoalProgress, error) {
+	var p GoalProgress
+
+	var topics []db.Topic
+	if err := db.DB.Where("goal_id = ? AND user_id = ?", goal.ID, goal.UserID).Find(&topics).Error; err != nil {
+		return p, err
+	}
+	p.TopicsTotal = len(topics)
+	for _, t := range topics {
+		if t.CompletionStatus == "completed" {
+			p.TopicsCompleted++
+		}
+	}
+
+	var docIds []string
+	if err := db.DB.Model(&db.GoalDocument{}).Where("goal_id = ?", goal.ID).Pluck("document_id", &docIds).Error; err != nil {
+		return p, err
+	}
+	p.DocumentsTotal = len(docIds)
+
+	var best []float64
+	if len(docIds) > 0 {
+		if err := db.DB.Model(&db.Quiz{}).
+			Where("user_id = ? AND document_id IN ? AND status = ? AND score IS NOT NULL", goal.UserID, docIds, "submitted").
+			Group("document_id").
+			Pluck("MAX(score)", &best).Error; err != nil {
+			return p, err
+		}
+	}
+	p.DocumentsQuizzed = len(best)
+
+	var sum float64
+	for _, s := range best {
+		sum += s
+	}
+	if len(best) > 0 {
+		avg := sum / float64(len(best))
+		p.QuizAverage = &avg
+	}
+
+	p.Percent = goalPercent(goal.Status, p, sum)
+	return p, nil
+}
+
+// goalPercent averages whichever components the goal has: the share of
+// completed topics and the sum of best quiz scores spread over all documents
+func goalPercent(status string, p GoalProgress, scoreSum float64) float64 {
+	if status == GoalCompleted {
+		return 100
+	}
+	var parts []float64
+	if p.TopicsTotal > 0 {
+		parts = append(parts, float64(p.TopicsCompleted)/float64(p.TopicsTotal))
+	}
+	if p.DocumentsTotal > 0 {
+		parts = append(parts, scoreSum/100/float64(p.DocumentsTotal))
+	}
+	if len(parts) == 0 {
+		return 0
+	}
+	var total float64
+	for _, part := range parts {
+		total += part
+	}
+	return total / float64(len(parts)) * 100
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"math"
+	"skillup-backend/db"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestValidateGoalTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{GoalActive, GoalActive, true},
+		{GoalActive, GoalCompleted, true},
+		{GoalActive, GoalArchived, true},
+		{GoalCompleted, GoalCompleted, true},
+		{GoalCompleted, GoalActive, true}, // reopen
+		{GoalCompleted, GoalArchived, true},
+		{GoalArchived, GoalArchived, true},
+		{GoalArchived, GoalActive, true},
+		{GoalArchived, GoalCompleted, false}, // reactivate first
+		{GoalActive, "paused", false},
+		{GoalActive, "", false},
+		{"", GoalActive, false},
+	}
+	for _, tt := range tests {
+		err := ValidateGoalTransition(tt.from, tt.to)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateGoalTransition(%q, %q) error = %v, want allowed %v", tt.from, tt.to, err, tt.ok)
+		}
+	}
+}
+
+func TestGoalPercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		p        GoalProgress
+		scoreSum float64
+		want     float64
+	}{
+		{name: "nothing to measure", status: GoalActive, want: 0},
+		{name: "topics only", status: GoalActive, p: GoalProgress{TopicsCompleted: 1, TopicsTotal: 4}, want: 25},
+		{name: "documents only", status: GoalActive, p: GoalProgress{DocumentsQuizzed: 1, DocumentsTotal: 2}, scoreSum: 80, want: 40},
+		{name: "unquizzed documents count as zero", status: GoalActive, p: GoalProgress{DocumentsTotal: 3}, want: 0},
+		{
+			name:     "topics and documents weigh equally",
+			status:   GoalActive,
+			p:        GoalProgress{TopicsCompleted: 1, TopicsTotal: 2, DocumentsQuizzed: 2, DocumentsTotal: 2},
+			scoreSum: 180,
+			want:     70,
+		},
+		{name: "archived keeps its progress", status: GoalArchived, p: GoalProgress{TopicsCompleted: 3, TopicsTotal: 4}, want: 75},
+		{name: "completed is always 100", status: GoalCompleted, p: GoalProgress{TopicsTotal: 4, DocumentsTotal: 1}, want: 100},
+	}
+	for _, tt := range tests {
+		if got := goalPercent(tt.status, tt.p, tt.scoreSum); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: goalPercent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestComputeGoalProgress(t *testing.T) {
+	useTestDB(t)
+	if err := db.DB.AutoMigrate(&db.Goal{}, &db.Topic{}, &db.GoalDocument{}, &db.Quiz{}); err != nil {
+		t.Fatal(err)
+	}
+	userID := uuid.NewString()
+	goal := &db.Goal{ID: uuid.NewString(), UserID: userID, Title: "Exam", Status: GoalActive}
+	t.Cleanup(func() {
+		db.DB.Where("user_id = ?", userID).Delete(&db.Topic{})
+		db.DB.Where("goal_id = ?", goal.ID).Delete(&db.GoalDocument{})
+		db.DB.Where("user_id = ?", userID).Delete(&db.Quiz{})
+		db.DB.Delete(goal)
+	})
+	if err := db.DB.Create(goal).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(v interface{}) {
+		t.Helper()
+		if err := db.DB.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, status := range []string{"completed", "pending"} {
+		create(&db.Topic{ID: uuid.NewString(), UserID: userID, GoalID: &goal.ID, Title: "Topic", CompletionStatus: status})
+	}
+	quizzed, unquizzed := uuid.NewString(), uuid.NewString()
+	for _, doc := range []string{quizzed, unquizzed} {
+		create(&db.GoalDocument{GoalID: goal.ID, DocumentID: doc})
+	}
+	quiz := func(userID, docID, status string, score float64) {
+		t.Helper()
+		create(&db.Quiz{
+			ID: uuid.NewString(), UserID: userID, DocumentID: docID, Questions: []byte("[]"),
+			TotalQuestions: 1, Status: status, Score: &score,
+		})
+	}
+	quiz(userID, quizzed, "submitted", 60)
+	quiz(userID, quizzed, "submitted", 80)             // the best score counts
+	quiz(userID, unquizzed, "in_progress", 100)        // not submitted
+	quiz(uuid.NewString(), unquizzed, "submitted", 90) // someone else's
+
+	p, err := ComputeGoalProgress(goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TopicsCompleted != 1 || p.TopicsTotal != 2 || p.DocumentsQuizzed != 1 || p.DocumentsTotal != 2 {
+		t.Errorf("progress = %+v, want 1/2 topics and 1/2 documents quizzed", p)
+	}
+	if p.QuizAverage == nil || *p.QuizAverage != 80 {
+		t.Errorf("quiz average = %v, want 80", p.QuizAverage)
+	}
+	// (50% of topics + 80/2% of documents) / 2
+	if math.Abs(p.Percent-45) > 1e-9 {
+		t.Errorf("percent = %v, want 45", p.Percent)
+	}
+}
@@ -0,0 +1,588 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"skillup-backend/db"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ingestion job states and stages
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+
+	StageQueued     = "queued"
+	StageExtracting = "extracting"
+	StageChunking   = "chunking"
+	StageEmbedding  = "embedding"
+	StageDone       = "done"
+)
+
+const (
+	ingestPollInterval = 5 * time.Second
+	ingestStaleAfter   = 5 * time.Minute // running jobs without a heartbeat this long are requeued
+	ingestBaseBackoff  = 10 * time.Second
+	ingestMaxBackoff   = 10 * time.Minute
+)
+
+// errPermanent marks failures that retrying cannot fix
+var errPermanent = errors.New("permanent ingestion failure")
+
+var (
+	// ErrIngestionInProgress means a document still has a queued or running job
+	ErrIngestionInProgress = errors.New("document is being processed")
+	// ErrNoSourceFile means the original upload was not stored, so it cannot be re-extracted
+	ErrNoSourceFile = errors.New("original file is not available")
+)
+
+// ingestWake nudges idle workers when a new job is enqueued
+var ingestWake = make(chan struct{}, 1)
+
+// StartIngestionWorkers requeues jobs orphaned by a crash and starts n workers
+func StartIngestionWorkers(n int) {
+	if n <= 0 {
+		n = 1
+	}
+
+	requeueStaleJobs()
+
+	for i := 0; i < n; i++ {
+		go ingestionWorker(i)
+	}
+
+	// Periodically recover jobs whose worker died (e.g. another instance crashed)
+	go func() {
+		for range time.Tick(ingestStaleAfter) {
+			requeueStaleJobs()
+		}
+	}()
+
+	log.Printf("Started %d ingestion workers", n)
+}
+
+// EnqueueIngestion schedules a document for extraction, chunking and embedding
+func EnqueueIngestion(documentID, userID string) (*db.IngestionJob, error) {
+	job := db.IngestionJob{
+		ID:          uuid.NewString(),
+		DocumentID:  documentID,
+		UserID:      userID,
+		Status:      JobQueued,
+		Stage:       StageQueued,
+		MaxAttempts: 5,
+		NextRunAt:   time.Now(),
+	}
+	if err := db.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case ingestWake <- struct{}{}:
+	default:
+	}
+	return &job, nil
+}
+
+// LatestIngestionJob returns the most recent job for a document
+func LatestIngestionJob(documentID string) (*db.IngestionJob, error) {
+	var job db.IngestionJob
+	if err := db.DB.Where("document_id = ?", documentID).Order("created_at desc").First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// IngestionInProgress reports whether the document has a queued or running job
+func IngestionInProgress(documentID string) (bool, error) {
+	var count int64
+	err := db.DB.Model(&db.IngestionJob{}).
+		Where("document_id = ? AND status IN ?", documentID, []string{JobQueued, JobRunning}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// LockIngestionJobs locks the queued and running jobs matching the condition
+// for the rest of tx, so no worker can claim them before tx deletes them.
+// Workers claim with SKIP LOCKED; one that is mid-claim finishes first and the
+// job shows up as running. A running job fails with ErrIngestionInProgress.
+func LockIngestionJobs(tx *gorm.DB, query string, args ...interface{}) error {
+	var jobs []db.IngestionJob
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(query, args...).
+		Where("status IN ?", []string{JobQueued, JobRunning}).
+		Find(&jobs).Error; err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Status == JobRunning {
+			return ErrIngestionInProgress
+		}
+	}
+	return nil
+}
+
+// ReprocessDocument discards a document's extracted pages and chunks and
+// queues it again, so it is re-extracted, re-chunked with the current chunker
+// settings and re-embedded with the current embedding model
+func ReprocessDocument(documentID, userID string) (*db.IngestionJob, error) {
+	busy, err := IngestionInProgress(documentID)
+	if err != nil {
+		return nil, err
+	}
+	if busy {
+		return nil, ErrIngestionInProgress
+	}
+
+	var size int64
+	if err := db.DB.Model(&db.DocumentRaw{}).Select("COALESCE(length(file_data), 0)").
+		Where("document_id = ?", documentID).Scan(&size).Error; err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, ErrNoSourceFile
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&db.DocumentPage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", documentID).Delete(&db.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&db.Document{}).Where("id = ?", documentID).Updates(map[string]interface{}{
+			"processing_status": "uploaded",
+			"chunk_strategy":    "",
+			"chunk_params":      nil,
+			"embedding_model":   "",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return EnqueueIngestion(documentID, userID)
+}
+
+func ingestionWorker(id int) {
+	for {
+		job, err := claimNextJob()
+		if err != nil {
+			log.Printf("ingestion worker %d: claim failed: %v", id, err)
+		}
+		if job == nil {
+			select {
+			case <-ingestWake:
+			case <-time.After(ingestPollInterval):
+			}
+			continue
+		}
+
+		runIngestionJob(job)
+	}
+}
+
+// claimNextJob atomically moves the oldest due job to running.
+// SKIP LOCKED lets several workers (or server instances) poll the same table.
+func claimNextJob() (*db.IngestionJob, error) {
+	var job db.IngestionJob
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", JobQueued, time.Now()).
+			Order("next_run_at").
+			First(&job).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		job.Status = JobRunning
+		job.Attempts++
+		job.StartedAt = &now
+		job.HeartbeatAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       job.Status,
+			"attempts":     job.Attempts,
+			"started_at":   now,
+			"heartbeat_at": now,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// requeueStaleJobs puts running jobs whose worker stopped heartbeating back in the queue
+func requeueStaleJobs() {
+	res := db.DB.Model(&db.IngestionJob{}).
+		Where("status = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", JobRunning, time.Now().Add(-ingestStaleAfter)).
+		Updates(map[string]interface{}{
+			"status":      JobQueued,
+			"next_run_at": time.Now(),
+		})
+	if res.Error != nil {
+		log.Println("warning: couldn't requeue stale ingestion jobs:", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Requeued %d interrupted ingestion jobs", res.RowsAffected)
+	}
+}
+
+func runIngestionJob(job *db.IngestionJob) {
+	db.DB.Model(&db.Document{}).Where("id = ?", job.DocumentID).Update("processing_status", "processing")
+
+	err := processDocument(job)
+	now := time.Now()
+
+	if err == nil {
+		db.DB.Model(job).Updates(map[string]interface{}{
+			"status":      JobSucceeded,
+			"stage":       StageDone,
+			"progress":    100,
+			"last_error":  "",
+			"finished_at": now,
+		})
+		db.DB.Model(&db.Document{}).Where("id = ?", job.DocumentID).Update("processing_status", "processed")
+		return
+	}
+
+	log.Printf("ingestion job %s (document %s) attempt %d failed: %v", job.ID, job.DocumentID, job.Attempts, err)
+
+	if isPermanentIngestError(err) || job.Attempts >= job.MaxAttempts {
+		db.DB.Model(job).Updates(map[string]interface{}{
+			"status":      JobFailed,
+			"last_error":  ingestErrorMessage(err),
+			"finished_at": now,
+		})
+		db.DB.Model(&db.Document{}).Where("id = ?", job.DocumentID).Update("processing_status", "failed")
+		return
+	}
+
+	db.DB.Model(job).Updates(map[string]interface{}{
+		"status":      JobQueued,
+		"last_error":  ingestErrorMessage(err),
+		"next_run_at": now.Add(ingestBackoff(job.Attempts)),
+	})
+}
+
+// processDocument runs the pipeline. Every stage is idempotent so a retried or
+// recovered job resumes where it stopped: extracted pages are kept in
+// document_pages and chunks that already have embeddings are skipped.
+func processDocument(job *db.IngestionJob) error {
+	// Extract
+	pages, err := loadPages(job.DocumentID)
+	if err != nil {
+		return err
+	}
+	if len(pages) == 0 {
+		updateJobProgress(job, StageExtracting, 0)
+		// An identical file that was already processed saves the whole pipeline
+		if reused, err := reuseIngestion(job); err != nil || reused {
+			return err
+		}
+		if pages, err = extractDocument(job); err != nil {
+			return err
+		}
+	}
+
+	// Chunk
+	updateJobProgress(job, StageChunking, 10)
+	opts, err := documentChunkOptions(job.DocumentID)
+	if err != nil {
+		return err
+	}
+	chunks := ChunkPages(pages, opts)
+	if len(chunks) == 0 {
+		return fmt.Errorf("%w: no text could be extracted from the file", errPermanent)
+	}
+	// Chapters of an EPUB or the single page of a text file are not pages a
+	// reader can look up, so their chunks carry no page numbers
+	var pageCount int
+	if err := db.DB.Model(&db.Document{}).Where("id = ?", job.DocumentID).Pluck("page_count", &pageCount).Error; err != nil {
+		return err
+	}
+	if pageCount == 0 {
+		for i := range chunks {
+			chunks[i].PageStart, chunks[i].PageEnd = 0, 0
+		}
+	}
+	cues, err := transcriptCues(job.DocumentID)
+	if err != nil {
+		return err
+	}
+	job.ChunksTotal = len(chunks)
+
+	if err := resetStaleEmbeddings(job.DocumentID); err != nil {
+		return err
+	}
+
+	var done []int
+	if err := db.DB.Model(&db.DocumentChunk{}).Where("document_id = ?", job.DocumentID).
+		Pluck("chunk_index", &done).Error; err != nil {
+		return err
+	}
+	embedded := make(map[int]bool, len(done))
+	for _, i := range done {
+		embedded[i] = true
+	}
+	job.ChunksDone = len(embedded)
+
+	// Embed
+	updateJobProgress(job, StageEmbedding, embeddingProgress(job))
+	for i, ch := range chunks {
+		if embedded[i] {
+			continue
+		}
+
+		emb, err := GetEmbedding(ch.Text)
+		if err != nil {
+			return err
+		}
+		startMs, endMs := cueSpan(cues, ch.CharStart, ch.CharEnd)
+		if err := db.DB.Create(&db.DocumentChunk{
+			ID:         uuid.NewString(),
+			DocumentID: job.DocumentID,
+			UserID:     job.UserID,
+			ChunkIndex: i,
+			ChunkText:  ch.Text,
+			PageStart:  optionalPage(ch.PageStart),
+			PageEnd:    optionalPage(ch.PageEnd),
+			StartMs:    startMs,
+			EndMs:      endMs,
+			CharStart:  ch.CharStart,
+			CharEnd:    ch.CharEnd,
+			Embedding:  emb,
+		}).Error; err != nil {
+			return err
+		}
+
+		job.ChunksDone++
+		updateJobProgress(job, StageEmbedding, embeddingProgress(job))
+	}
+
+	return nil
+}
+
+// extractDocument pulls per-page text and metadata out of the stored file and
+// saves pages, full text and metadata in one transaction. Formats without
+// real pages get a page_count of 0.
+func extractDocument(job *db.IngestionJob) ([]string, error) {
+	var raw db.DocumentRaw
+	if err := db.DB.Where("document_id = ?", job.DocumentID).First(&raw).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: document file not found", errPermanent)
+		}
+		return nil, err
+	}
+	var mimeType string
+	if err := db.DB.Model(&db.Document{}).Where("id = ?", job.DocumentID).Pluck("mime_type", &mimeType).Error; err != nil {
+		return nil, err
+	}
+
+	extracted, err := ExtractDocument(mimeType, raw.FileData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	pageCount := 0
+	if extracted.Paginated {
+		pageCount = extracted.PageCount
+	}
+	if strings.TrimSpace(extracted.FullText()) == "" {
+		return nil, fmt.Errorf("%w: no text could be extracted from the file", errPermanent)
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", job.DocumentID).Delete(&db.DocumentPage{}).Error; err != nil {
+			return err
+		}
+
+		offset := 0
+		rows := make([]db.DocumentPage, 0, len(extracted.Pages))
+		for i, text := range extracted.Pages {
+			rows = append(rows, db.DocumentPage{
+				ID:         uuid.NewString(),
+				DocumentID: job.DocumentID,
+				UserID:     job.UserID,
+				PageNumber: i + 1,
+				Text:       text,
+				CharStart:  offset,
+			})
+			offset += utf8.RuneCountInString(text) + 1 // pages are joined with "\n"
+		}
+		if err := tx.CreateInBatches(rows, 100).Error; err != nil {
+			return err
+		}
+
+		var cues datatypes.JSON
+		if extracted.Cues != nil {
+			data, err := json.Marshal(extracted.Cues)
+			if err != nil {
+				return err
+			}
+			cues = datatypes.JSON(data)
+		}
+		if err := tx.Model(&raw).Updates(map[string]interface{}{"text": extracted.FullText(), "cues": cues}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&db.Document{}).Where("id = ?", job.DocumentID).Updates(map[string]interface{}{
+			"title":      extracted.Title,
+			"author":     extracted.Author,
+			"page_count": pageCount,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return extracted.Pages, nil
+}
+
+// transcriptCues loads the subtitle cues saved at extraction; nil for documents
+// that are not transcripts
+func transcriptCues(documentID string) ([]TranscriptCue, error) {
+	var raw db.DocumentRaw
+	if err := db.DB.Select("id", "cues").Where("document_id = ?", documentID).First(&raw).Error; err != nil {
+		return nil, err
+	}
+	if len(raw.Cues) == 0 {
+		return nil, nil
+	}
+	var cues []TranscriptCue
+	if err := json.Unmarshal(raw.Cues, &cues); err != nil {
+		return nil, fmt.Errorf("%w: invalid transcript cues: %v", errPermanent, err)
+	}
+	return cues, nil
+}
+
+// documentChunkOptions returns the chunker settings recorded on the document,
+// recording the current defaults first if there are none. Retries therefore
+// re-chunk exactly like the attempt whose embeddings they are resuming.
+func documentChunkOptions(documentID string) (ChunkOptions, error) {
+	var doc db.Document
+	if err := db.DB.Select("id", "chunk_strategy", "chunk_params").Where("id = ?", documentID).First(&doc).Error; err != nil {
+		return ChunkOptions{}, err
+	}
+
+	var opts ChunkOptions
+	if len(doc.ChunkParams) > 0 && json.Unmarshal(doc.ChunkParams, &opts) == nil && opts.Strategy != "" {
+		return opts.normalized(), nil
+	}
+
+	opts = DefaultChunkOptions()
+	params, err := json.Marshal(opts)
+	if err != nil {
+		return ChunkOptions{}, err
+	}
+	err = db.DB.Model(&db.Document{}).Where("id = ?", documentID).Updates(map[string]interface{}{
+		"chunk_strategy": opts.Strategy,
+		"chunk_params":   datatypes.JSON(params),
+	}).Error
+	return opts, err
+}
+
+// resetStaleEmbeddings drops stored chunks embedded with a different model than
+// the current one, so a resumed job never mixes vectors from two models, and
+// records the current model on the document
+func resetStaleEmbeddings(documentID string) error {
+	var doc db.Document
+	if err := db.DB.Select("id", "embedding_model").Where("id = ?", documentID).First(&doc).Error; err != nil {
+		return err
+	}
+	model := EmbeddingModel()
+	if doc.EmbeddingModel == model {
+		return nil
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		if doc.EmbeddingModel != "" {
+			if err := tx.Where("document_id = ?", documentID).Delete(&db.DocumentChunk{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&db.Document{}).Where("id = ?", documentID).Update("embedding_model", model).Error
+	})
+}
+
+// loadPages returns the stored page texts of a document in page order
+func loadPages(documentID string) ([]string, error) {
+	var rows []db.DocumentPage
+	if err := db.DB.Where("document_id = ?", documentID).Order("page_number").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	pages := make([]string, len(rows))
+	for i, r := range rows {
+		pages[i] = r.Text
+	}
+	return pages, nil
+}
+
+func optionalPage(page int) *int {
+	if page <= 0 {
+		return nil
+	}
+	return &page
+}
+
+// embeddingProgress maps embedded chunks onto the 15-99% range
+func embeddingProgress(job *db.IngestionJob) int {
+	if job.ChunksTotal == 0 {
+		return 99
+	}
+	return 15 + job.ChunksDone*84/job.ChunksTotal
+}
+
+// updateJobProgress records stage/progress and doubles as the job heartbeat
+func updateJobProgress(job *db.IngestionJob, stage string, progress int) {
+	now := time.Now()
+	job.Stage = stage
+	job.Progress = progress
+	job.HeartbeatAt = &now
+	db.DB.Model(job).Updates(map[string]interface{}{
+		"stage":        stage,
+		"progress":     progress,
+		"chunks_total": job.ChunksTotal,
+		"chunks_done":  job.ChunksDone,
+		"heartbeat_at": now,
+	})
+}
+
+// isPermanentIngestError reports whether retrying would just fail again
+func isPermanentIngestError(err error) bool {
+	return errors.Is(err, errPermanent) ||
+		errors.Is(err, ErrLLMAuth) ||
+		errors.Is(err, ErrLLMBlocked)
+}
+
+// ingestErrorMessage is what users see of a failed attempt: the problem with
+// the file for permanent failures, the kind of an AI provider failure, and
+// nothing more specific for anything else. The full error is only logged.
+func ingestErrorMessage(err error) string {
+	if kind := LLMErrorKind(err); kind != "" {
+		return kind
+	}
+	if errors.Is(err, errPermanent) {
+		return err.Error()
+	}
+	return "internal error while processing the document"
+}
+
+// ingestBackoff is exponential with jitter: 10s, 20s, 40s, ... capped at 10m
+func ingestBackoff(attempt int) time.Duration {
+	d := ingestBaseBackoff << uint(attempt-1)
+	if d <= 0 || d > ingestMaxBackoff {
+		d = ingestMaxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"skillup-backend/db"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestLockIngestionJobs(t *testing.T) {
+	useTestDB(t)
+	userID := uuid.NewString()
+	t.Cleanup(func() { db.DB.Where("user_id = ?", userID).Delete(&db.IngestionJob{}) })
+
+	newJob := func(status string) *db.IngestionJob {
+		t.Helper()
+		job := &db.IngestionJob{
+			ID:         uuid.NewString(),
+			DocumentID: uuid.NewString(),
+			UserID:     userID,
+			Status:     status,
+			Stage:      StageQueued,
+			NextRunAt:  time.Now().Add(-time.Hour),
+		}
+		if err := db.DB.Create(job).Error; err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+
+	// A locked queued job cannot be claimed until the transaction ends
+	queued := newJob(JobQueued)
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := LockIngestionJobs(tx, "document_id = ?", queued.DocumentID); err != nil {
+			return err
+		}
+		claimed, err := claimNextJob()
+		if err != nil {
+			return err
+		}
+		if claimed != nil && claimed.ID == queued.ID {
+			t.Error("a worker claimed a locked job")
+		}
+		return tx.Where("id = ?", queued.ID).Delete(&db.IngestionJob{}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A running job cannot be deleted from under its worker
+	running := newJob(JobRunning)
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		return LockIngestionJobs(tx, "user_id = ?", userID)
+	})
+	if !errors.Is(err, ErrIngestionInProgress) {
+		t.Errorf("running job %s: error = %v, want ErrIngestionInProgress", running.ID, err)
+	}
+
+	// Finished jobs are no obstacle
+	db.DB.Model(running).Update("status", JobSucceeded)
+	newJob(JobFailed)
+	if err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return LockIngestionJobs(tx, "user_id = ?", userID)
+	}); err != nil {
+		t.Errorf("finished jobs: %v", err)
+	}
+}
+
+func TestIngestErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{
+			&LLMError{Kind: ErrLLMUnavailable, Provider: "gemini", Message: `Post "https://example.test/v1?key=secret": connection refused`},
+			ErrLLMUnavailable.Error(),
+		},
+		{
+			fmt.Errorf("%w: no text could be extracted from the file", errPermanent),
+			"permanent ingestion failure: no text could be extracted from the file",
+		},
+		{
+			errors.New(`ERROR: relation "document_chunks" does not exist (SQLSTATE 42P01)`),
+			"internal error while processing the document",
+		},
+	}
+	for _, tt := range tests {
+		if got := ingestErrorMessage(tt.err); got != tt.want {
+			t.Errorf("ingestErrorMessage(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
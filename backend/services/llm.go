@@ -1,88 +1,116 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"log"
 	"skillup-backend/config"
+	"strings"
+	"sync"
 )
 
-// Gemini chat request structure
-type geminiChatReq struct {
-	Contents []geminiContent `json:"contents"`
+// GenerateOptions controls a single generation request.
+// Zero values mean "use the provider default".
+type GenerateOptions struct {
+	SystemPrompt string
+	Temperature  *float64
+	MaxTokens    int
 }
 
-type geminiContent struct {
-	Parts []geminiPart `json:"parts"`
+// Provider is implemented by every LLM backend SkillUp can talk to.
+// Embed returns the embedding of input under the configured EMBED_MODEL.
+type Provider interface {
+	Name() string
+	Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error)
+	Embed(ctx context.Context, input string) ([]float32, error)
 }
 
-type geminiPart struct {
-	Text string `json:"text"`
+// StreamingProvider is a Provider that can deliver output incrementally.
+// onToken is called for every text delta; returning an error aborts the stream.
+// Stream returns everything received so far, even when it fails part-way.
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, prompt string, opts GenerateOptions, onToken func(string) error) (string, error)
 }
 
-// Gemini chat response structure
-type geminiChatResp struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
+var (
+	llmProvider Provider
+	llmOnce     sync.Once
+)
+
+// InitLLM selects the LLM provider from config and exits if LLM_PROVIDER is
+// not a known provider. Safe to call more than once.
+func InitLLM() {
+	llmOnce.Do(func() {
+		// Falling back to a hosted provider would send documents somewhere
+		// the operator did not choose, so a bad setting stops startup
+		p, err := NewProvider(config.AppConfig)
+		if err != nil {
+			log.Fatalf("%v; use gemini, openai or ollama", err)
+		}
+		llmProvider = p
+		log.Printf("LLM provider: %s", p.Name())
+	})
 }
 
-// LLM generates text response using Gemini API
-func LLM(prompt string) string {
-	if prompt == "" {
-		return ""
+// NewProvider builds the provider named by cfg.LLM_PROVIDER
+func NewProvider(cfg config.Config) (Provider, error) {
+	switch strings.ToLower(cfg.LLM_PROVIDER) {
+	case "", "gemini":
+		return newGeminiProvider(cfg), nil
+	case "openai":
+		return newOpenAIProvider(cfg), nil
+	case "ollama":
+		return newOllamaProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM_PROVIDER %q", cfg.LLM_PROVIDER)
 	}
+}
 
-	// Build Gemini API request
-	reqBody := geminiChatReq{
-		Contents: []geminiContent{
-			{
-				Parts: []geminiPart{
-					{Text: prompt},
-				},
-			},
-		},
-	}
+// GetProvider returns the configured provider
+func GetProvider() Provider {
+	InitLLM()
+	return llmProvider
+}
 
-	b, err := json.Marshal(reqBody)
-	if err != nil {
-		return "LLM request failed"
+// LLM generates text response using the configured provider.
+// Failures are returned as *LLMError; see errors.go for the kinds.
+func LLM(prompt string) (string, error) {
+	if prompt == "" {
+		return "", nil
 	}
 
-	// Call Gemini generateContent API
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s",
-		config.AppConfig.GEMINI_MODEL, config.AppConfig.GEMINI_API_KEY)
-	
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(b))
+	out, err := GetProvider().Generate(context.Background(), prompt, GenerateOptions{})
 	if err != nil {
-		return "LLM request failed"
+		return "", err
 	}
-	req.Header.Set("Content-Type", "application/json")
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "LLM request failed"
+	if strings.TrimSpace(out) == "" {
+		return "", newLLMError(GetProvider().Name(), ErrLLMBadResponse, "empty response")
 	}
-	defer res.Body.Close()
+	return out, nil
+}
 
-	if res.StatusCode != http.StatusOK {
-		return "LLM request failed"
+// LLMStream generates a response and forwards it token by token. Providers
+// without streaming support deliver the whole answer as a single token.
+func LLMStream(ctx context.Context, prompt string, onToken func(string) error) (string, error) {
+	p := GetProvider()
+	if sp, ok := p.(StreamingProvider); ok {
+		return sp.Stream(ctx, prompt, GenerateOptions{}, onToken)
 	}
 
-	var out geminiChatResp
-	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
-		return "LLM request failed"
+	out, err := p.Generate(ctx, prompt, GenerateOptions{})
+	if err != nil {
+		return "", err
 	}
+	return out, onToken(out)
+}
 
-	if len(out.Candidates) == 0 || len(out.Candidates[0].Content.Parts) == 0 {
-		return ""
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
 	}
-
-	return out.Candidates[0].Content.Parts[0].Text
+	return ""
 }
-
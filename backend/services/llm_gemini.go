@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"skillup-backend/config"
+	"strings"
+)
+
+const geminiDefaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+
+// Gemini chat request structure
+type geminiChatReq struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+}
+
+// Gemini chat response structure
+type geminiChatResp struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+}
+
+// geminiBlockedReasons are finish reasons that mean the output was withheld
+var geminiBlockedReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+}
+
+// text returns the generated text or a typed error when nothing usable came back
+func (r *geminiChatResp) text() (string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", newLLMError("gemini", ErrLLMBlocked, r.PromptFeedback.BlockReason)
+	}
+	if len(r.Candidates) == 0 {
+		return "", newLLMError("gemini", ErrLLMBadResponse, "no candidates returned")
+	}
+
+	cand := r.Candidates[0]
+	if geminiBlockedReasons[cand.FinishReason] {
+		return "", newLLMError("gemini", ErrLLMBlocked, cand.FinishReason)
+	}
+
+	var sb strings.Builder
+	for _, p := range cand.Content.Parts {
+		sb.WriteString(p.Text)
+	}
+	return sb.String(), nil
+}
+
+// Gemini embedContent request structure
+type geminiEmbedReq struct {
+	Content geminiContent `json:"content"`
+}
+
+// Gemini embedContent response structure
+type geminiEmbedResp struct {
+	Embedding struct {
+		Values []float32 `json:"values"`
+	} `json:"embedding"`
+}
+
+// geminiProvider calls Google's generateContent and embedContent APIs
+type geminiProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	embedModel string
+}
+
+func newGeminiProvider(cfg config.Config) *geminiProvider {
+	return &geminiProvider{
+		baseURL:    strings.TrimRight(firstNonEmpty(cfg.LLM_BASE_URL, geminiDefaultBaseURL), "/"),
+		apiKey:     firstNonEmpty(cfg.LLM_API_KEY, cfg.GEMINI_API_KEY),
+		model:      firstNonEmpty(cfg.LLM_MODEL, cfg.GEMINI_MODEL),
+		embedModel: cfg.EMBED_MODEL,
+	}
+}
+
+func (p *geminiProvider) Name() string { return "gemini" }
+
+func (p *geminiProvider) buildRequest(prompt string, opts GenerateOptions) geminiChatReq {
+	reqBody := geminiChatReq{
+		Contents: []geminiContent{
+			{
+				Role:  "user",
+				Parts: []geminiPart{{Text: prompt}},
+			},
+		},
+	}
+	if opts.SystemPrompt != "" {
+		reqBody.SystemInstruction = &geminiContent{
+			Parts: []geminiPart{{Text: opts.SystemPrompt}},
+		}
+	}
+	if opts.Temperature != nil || opts.MaxTokens > 0 {
+		reqBody.GenerationConfig = &geminiGenerationConfig{
+			Temperature:     opts.Temperature,
+			MaxOutputTokens: opts.MaxTokens,
+		}
+	}
+	return reqBody
+}
+
+func (p *geminiProvider) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	b, err := json.Marshal(p.buildRequest(prompt, opts))
+	if err != nil {
+		return "", err
+	}
+
+	// Call Gemini generateContent API
+	url := fmt.Sprintf("%s/models/%s:generateContent", p.baseURL, p.model)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.apiKey) // kept out of the URL, which ends up in errors and logs
+
+	res, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return "", errorFromTransport("gemini", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errorFromStatus("gemini", res)
+	}
+
+	var out geminiChatResp
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", newLLMError("gemini", ErrLLMBadResponse, err.Error())
+	}
+
+	return out.text()
+}
+
+// delta returns the text carried by one streamed chunk
+func (r *geminiChatResp) delta() (string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", newLLMError("gemini", ErrLLMBlocked, r.PromptFeedback.BlockReason)
+	}
+	if len(r.Candidates) == 0 {
+		return "", nil
+	}
+	if geminiBlockedReasons[r.Candidates[0].FinishReason] {
+		return "", newLLMError("gemini", ErrLLMBlocked, r.Candidates[0].FinishReason)
+	}
+
+	var sb strings.Builder
+	for _, p := range r.Candidates[0].Content.Parts {
+		sb.WriteString(p.Text)
+	}
+	return sb.String(), nil
+}
+
+func (p *geminiProvider) Stream(ctx context.Context, prompt string, opts GenerateOptions, onToken func(string) error) (string, error) {
+	b, err := json.Marshal(p.buildRequest(prompt, opts))
+	if err != nil {
+		return "", err
+	}
+
+	// streamGenerateContent with alt=sse returns one JSON response per event
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.baseURL, p.model)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.apiKey)
+
+	res, err := llmStreamClient.Do(req)
+	if err != nil {
+		return "", errorFromTransport("gemini", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errorFromStatus("gemini", res)
+	}
+
+	var full strings.Builder
+	err = readSSE(res.Body, func(data []byte) (bool, error) {
+		var chunk geminiChatResp
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, newLLMError("gemini", ErrLLMBadResponse, err.Error())
+		}
+		text, err := chunk.delta()
+		if err != nil || text == "" {
+			return false, err
+		}
+		full.WriteString(text)
+		return false, onToken(text)
+	})
+	if err != nil {
+		return full.String(), streamError("gemini", err)
+	}
+	return full.String(), nil
+}
+
+func (p *geminiProvider) Embed(ctx context.Context, input string) ([]float32, error) {
+	b, err := json.Marshal(geminiEmbedReq{Content: geminiContent{Parts: []geminiPart{{Text: input}}}})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/models/%s:embedContent", p.baseURL, p.embedModel)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", p.apiKey)
+
+	res, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return nil, errorFromTransport("gemini-embedding", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errorFromStatus("gemini-embedding", res)
+	}
+
+	var out geminiEmbedResp
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, newLLMError("gemini-embedding", ErrLLMBadResponse, err.Error())
+	}
+	return out.Embedding.Values, nil
+}
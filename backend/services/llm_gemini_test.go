@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"skillup-backend/config"
+	"strings"
+	"testing"
+)
+
+func TestGeminiKeyStaysOutOfURL(t *testing.T) {
+	const key = "secret-gemini-key"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.String(), key) {
+			t.Errorf("API key in request URL %s", r.URL)
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != key {
+			t.Errorf("x-goog-api-key = %q, want the API key", got)
+		}
+		if strings.HasSuffix(r.URL.Path, ":embedContent") {
+			w.Write([]byte(`{"embedding":{"values":[1,2]}}`))
+			return
+		}
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}`))
+	}))
+	defer srv.Close()
+
+	cfg := config.Config{LLM_PROVIDER: "gemini", LLM_BASE_URL: srv.URL, LLM_API_KEY: key, LLM_MODEL: "m", EMBED_MODEL: "e"}
+	p, err := NewProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Generate(context.Background(), "hello", GenerateOptions{}); err != nil {
+		t.Errorf("Generate: %v", err)
+	}
+	if _, err := p.Embed(context.Background(), "hello"); err != nil {
+		t.Errorf("Embed: %v", err)
+	}
+
+	// Transport errors do not repeat the request URL
+	srv.Close()
+	cfg.LLM_BASE_URL = srv.URL + "/v1?key=" + key
+	p, _ = NewProvider(cfg)
+	_, err = p.Generate(context.Background(), "hello", GenerateOptions{})
+	if err == nil {
+		t.Fatal("Generate against a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), key) || strings.Contains(err.Error(), srv.URL) {
+		t.Errorf("error %q contains the request URL", err)
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"skillup-backend/config"
+	"strings"
+)
+
+const ollamaDefaultBaseURL = "http://localhost:11434"
+
+// Ollama /api/chat request structure
+type ollamaChatReq struct {
+	Model    string          `json:"model"`
+	Messages []openAIMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+}
+
+// Ollama /api/chat response structure
+type ollamaChatResp struct {
+	Message openAIMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// Ollama /api/embed request structure
+type ollamaEmbedReq struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// Ollama /api/embed response structure
+type ollamaEmbedResp struct {
+	Embeddings [][]float32 `json:"embeddings"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// ollamaProvider calls an Ollama-compatible /api/chat and /api/embed endpoint
+type ollamaProvider struct {
+	baseURL    string
+	model      string
+	embedModel string
+}
+
+func newOllamaProvider(cfg config.Config) *ollamaProvider {
+	return &ollamaProvider{
+		baseURL:    strings.TrimRight(firstNonEmpty(cfg.LLM_BASE_URL, ollamaDefaultBaseURL), "/"),
+		model:      cfg.LLM_MODEL,
+		embedModel: cfg.EMBED_MODEL,
+	}
+}
+
+func (p *ollamaProvider) Name() string { return "ollama" }
+
+func (p *ollamaProvider) buildRequest(prompt string, opts GenerateOptions, stream bool) ollamaChatReq {
+	var messages []openAIMessage
+	if opts.SystemPrompt != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: opts.SystemPrompt})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: prompt})
+
+	reqBody := ollamaChatReq{
+		Model:    p.model,
+		Messages: messages,
+		Stream:   stream,
+	}
+	if opts.Temperature != nil || opts.MaxTokens > 0 {
+		reqBody.Options = &ollamaOptions{
+			Temperature: opts.Temperature,
+			NumPredict:  opts.MaxTokens,
+		}
+	}
+	return reqBody
+}
+
+func (p *ollamaProvider) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	b, err := json.Marshal(p.buildRequest(prompt, opts, false))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewBuffer(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return "", errorFromTransport("ollama", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errorFromStatus("ollama", res)
+	}
+
+	var out ollamaChatResp
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", newLLMError("ollama", ErrLLMBadResponse, err.Error())
+	}
+	if out.Error != "" {
+		return "", newLLMError("ollama", ErrLLMBadResponse, out.Error)
+	}
+
+	return out.Message.Content, nil
+}
+
+func (p *ollamaProvider) Stream(ctx context.Context, prompt string, opts GenerateOptions, onToken func(string) error) (string, error) {
+	b, err := json.Marshal(p.buildRequest(prompt, opts, true))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/chat", bytes.NewBuffer(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := llmStreamClient.Do(req)
+	if err != nil {
+		return "", errorFromTransport("ollama", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errorFromStatus("ollama", res)
+	}
+
+	// Ollama streams newline-delimited JSON objects rather than SSE
+	var full strings.Builder
+	err = readNDJSON(res.Body, func(line []byte) (bool, error) {
+		var chunk ollamaChatResp
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return false, newLLMError("ollama", ErrLLMBadResponse, err.Error())
+		}
+		if chunk.Error != "" {
+			return false, newLLMError("ollama", ErrLLMBadResponse, chunk.Error)
+		}
+		if text := chunk.Message.Content; text != "" {
+			full.WriteString(text)
+			if err := onToken(text); err != nil {
+				return false, err
+			}
+		}
+		return chunk.Done, nil
+	})
+	if err != nil {
+		return full.String(), streamError("ollama", err)
+	}
+	return full.String(), nil
+}
+
+func (p *ollamaProvider) Embed(ctx context.Context, input string) ([]float32, error) {
+	b, err := json.Marshal(ollamaEmbedReq{Model: p.embedModel, Input: input})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/api/embed", bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return nil, errorFromTransport("ollama-embedding", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errorFromStatus("ollama-embedding", res)
+	}
+
+	var out ollamaEmbedResp
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, newLLMError("ollama-embedding", ErrLLMBadResponse, err.Error())
+	}
+	if out.Error != "" {
+		return nil, newLLMError("ollama-embedding", ErrLLMBadResponse, out.Error)
+	}
+	if len(out.Embeddings) == 0 {
+		return nil, newLLMError("ollama-embedding", ErrLLMBadResponse, "no embeddings returned")
+	}
+	return out.Embeddings[0], nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"skillup-backend/config"
+	"strings"
+)
+
+const openAIDefaultBaseURL = "https://api.openai.com/v1"
+
+// OpenAI chat completions request structure
+type openAIChatReq struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// OpenAI chat completions response structure
+type openAIChatResp struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// OpenAI streamed chunk structure
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta        openAIMessage `json:"delta"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// OpenAI embeddings request structure
+type openAIEmbedReq struct {
+	Model string `json:"model"`
+	Input string `json:"input"`
+}
+
+// OpenAI embeddings response structure
+type openAIEmbedResp struct {
+	Data []struct {
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// openAIProvider calls any OpenAI-compatible /chat/completions and
+// /embeddings endpoint (OpenAI, vLLM, llama.cpp server, LM Studio, ...)
+type openAIProvider struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	embedModel string
+}
+
+func newOpenAIProvider(cfg config.Config) *openAIProvider {
+	return &openAIProvider{
+		baseURL:    strings.TrimRight(firstNonEmpty(cfg.LLM_BASE_URL, openAIDefaultBaseURL), "/"),
+		apiKey:     cfg.LLM_API_KEY,
+		model:      cfg.LLM_MODEL,
+		embedModel: cfg.EMBED_MODEL,
+	}
+}
+
+func (p *openAIProvider) Name() string { return "openai" }
+
+func (p *openAIProvider) buildRequest(prompt string, opts GenerateOptions) openAIChatReq {
+	var messages []openAIMessage
+	if opts.SystemPrompt != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: opts.SystemPrompt})
+	}
+	messages = append(messages, openAIMessage{Role: "user", Content: prompt})
+
+	return openAIChatReq{
+		Model:       p.model,
+		Messages:    messages,
+		Temperature: opts.Temperature,
+		MaxTokens:   opts.MaxTokens,
+	}
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	b, err := json.Marshal(p.buildRequest(prompt, opts))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewBuffer(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return "", errorFromTransport("openai", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errorFromStatus("openai", res)
+	}
+
+	var out openAIChatResp
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return "", newLLMError("openai", ErrLLMBadResponse, err.Error())
+	}
+
+	if len(out.Choices) == 0 {
+		return "", newLLMError("openai", ErrLLMBadResponse, "no choices returned")
+	}
+	if out.Choices[0].FinishReason == "content_filter" {
+		return "", newLLMError("openai", ErrLLMBlocked, "content_filter")
+	}
+
+	return out.Choices[0].Message.Content, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, prompt string, opts GenerateOptions, onToken func(string) error) (string, error) {
+	reqBody := p.buildRequest(prompt, opts)
+	reqBody.Stream = true
+
+	b, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewBuffer(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := llmStreamClient.Do(req)
+	if err != nil {
+		return "", errorFromTransport("openai", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", errorFromStatus("openai", res)
+	}
+
+	var full strings.Builder
+	err = readSSE(res.Body, func(data []byte) (bool, error) {
+		if string(data) == "[DONE]" {
+			return true, nil
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return false, newLLMError("openai", ErrLLMBadResponse, err.Error())
+		}
+		if len(chunk.Choices) == 0 {
+			return false, nil
+		}
+		if chunk.Choices[0].FinishReason == "content_filter" {
+			return false, newLLMError("openai", ErrLLMBlocked, "content_filter")
+		}
+		text := chunk.Choices[0].Delta.Content
+		if text == "" {
+			return false, nil
+		}
+		full.WriteString(text)
+		return false, onToken(text)
+	})
+	if err != nil {
+		return full.String(), streamError("openai", err)
+	}
+	return full.String(), nil
+}
+
+func (p *openAIProvider) Embed(ctx context.Context, input string) ([]float32, error) {
+	b, err := json.Marshal(openAIEmbedReq{Model: p.embedModel, Input: input})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/embeddings", bytes.NewBuffer(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := llmHTTPClient.Do(req)
+	if err != nil {
+		return nil, errorFromTransport("openai-embedding", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errorFromStatus("openai-embedding", res)
+	}
+
+	var out openAIEmbedResp
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, newLLMError("openai-embedding", ErrLLMBadResponse, err.Error())
+	}
+	if len(out.Data) == 0 {
+		return nil, newLLMError("openai-embedding", ErrLLMBadResponse, "no embeddings returned")
+	}
+	return out.Data[0].Embedding, nil
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"skillup-backend/config"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email is a plain-text message
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Email) error
+}
+
+var (
+	mailer     Mailer
+	mailerOnce sync.Once
+)
+
+// InitMailer selects the mailer from config. Safe to call more than once.
+func InitMailer() {
+	mailerOnce.Do(func() {
+		switch strings.ToLower(config.AppConfig.MAILER) {
+		case "smtp":
+			mailer = NewSMTPMailer(config.AppConfig)
+		case "", "log":
+			mailer = LogMailer{}
+		default:
+			log.Printf("warning: unknown MAILER %q — falling back to log", config.AppConfig.MAILER)
+			mailer = LogMailer{}
+		}
+	})
+}
+
+// GetMailer returns the active mailer, initializing it on first use
+func GetMailer() Mailer {
+	InitMailer()
+	return mailer
+}
+
+// SetMailer replaces the active mailer, e.g. with a fake in tests
+func SetMailer(m Mailer) {
+	InitMailer()
+	mailer = m
+}
+
+// LogMailer prints messages instead of sending them, for development
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Email) error {
+	log.Printf("email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// SMTPMailer sends through an SMTP server, using STARTTLS when the server
+// offers it. Without credentials it sends unauthenticated, which is what
+// local SMTP sinks like MailHog or Mailpit expect.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailer builds an SMTPMailer from the SMTP_* settings
+func NewSMTPMailer(cfg config.Config) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     cfg.SMTP_HOST,
+		Port:     cfg.SMTP_PORT,
+		Username: cfg.SMTP_USERNAME,
+		Password: cfg.SMTP_PASSWORD,
+		From:     cfg.MAIL_FROM,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Email) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid MAIL_FROM: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{to.Address}, buildMessage(from, to, msg))
+}
+
+// buildMessage renders the headers and body of a UTF-8 plain-text email
+func buildMessage(from, to *mail.Address, msg Email) []byte {
+	var sb strings.Builder
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, h := range headers {
+		sb.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	sb.WriteString("\r\n")
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	sb.WriteString(body)
+	return []byte(sb.String())
+}
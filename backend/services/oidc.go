@@ -0,0 +1,405 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"skillup-backend/config"
+	"skillup-backend/db"
+	"skillup-backend/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// OIDCStateTTL bounds how long the user may take at the provider
+	OIDCStateTTL = 10 * time.Minute
+	// oidcCacheTTL is how long discovery metadata and signing keys are cached
+	oidcCacheTTL = time.Hour
+)
+
+var (
+	ErrOIDCDisabled         = errors.New("single sign-on is not configured")
+	ErrOIDCInvalidState     = errors.New("sign-in request expired or is invalid")
+	ErrOIDCEmailNotVerified = errors.New("the identity provider did not confirm a verified email address")
+	// ErrOIDCAccountNotVerified means a local account has the provider's email
+	// but never proved it owns the address, so it is not linked
+	ErrOIDCAccountNotVerified = errors.New("an account with this email exists but its address is not verified; verify it or reset the password first")
+)
+
+var oidcHTTPClient = &http.Client{Timeout: 15 * time.Second}
+
+// oidcMetadata is the part of the provider's discovery document we use
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims are the ID token claims used to find or create the user
+type idTokenClaims struct {
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // some providers send "true"
+	Name          string      `json:"name"`
+	jwt.RegisteredClaims
+}
+
+func (c *idTokenClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// oidcCache holds discovery metadata and signing keys of the configured issuer
+var oidcCache struct {
+	sync.Mutex
+	meta   *oidcMetadata
+	metaAt time.Time
+	keys   map[string]*rsa.PublicKey
+	keysAt time.Time
+}
+
+// OIDCEnabled reports whether single sign-on is configured
+func OIDCEnabled() bool {
+	return config.AppConfig.OIDC_ISSUER != "" && config.AppConfig.OIDC_CLIENT_ID != ""
+}
+
+// BeginOIDCLogin starts an authorization code flow with PKCE and returns the
+// provider URL to send the browser to, and the state the callback must carry.
+// The caller binds the state to the browser with OIDCStateBinding so a
+// callback started elsewhere cannot sign this browser in.
+func BeginOIDCLogin(ctx context.Context) (authURL, state string, err error) {
+	if !OIDCEnabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	meta, err := oidcDiscover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err = utils.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	if err := db.DB.Create(&db.OIDCLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}).Error; err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {config.AppConfig.OIDC_CLIENT_ID},
+		"redirect_uri":          {config.AppConfig.OIDC_REDIRECT_URL},
+		"scope":                 {config.AppConfig.OIDC_SCOPES},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), state, nil
+}
+
+// OIDCStateBinding is the value of the browser cookie that ties a sign-in
+// state to the browser that started it. It is a MAC of the state, so the
+// cookie alone cannot be replayed as a state.
+func OIDCStateBinding(state string) string {
+	return utils.SignToken("oidc-state:" + state)
+}
+
+// CheckOIDCStateBinding reports whether a cookie value binds the state
+func CheckOIDCStateBinding(binding, state string) bool {
+	return binding != "" && state != "" && hmac.Equal([]byte(binding), []byte(OIDCStateBinding(state)))
+}
+
+// CompleteOIDCLogin handles the provider's callback: it checks the state,
+// exchanges the code, verifies the ID token and returns the signed-in user.
+// Identities are linked to an existing user with the same verified email,
+// provided that user has verified it too; otherwise a user without a
+// password is created.
+func CompleteOIDCLogin(ctx context.Context, state, code string) (*db.User, error) {
+	if !OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	// Deleting the state makes it single-use
+	var login db.OIDCLoginState
+	res := db.DB.Clauses(clause.Returning{}).Where("state = ? AND expires_at > ?", state, time.Now()).Delete(&login)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 || code == "" {
+		return nil, ErrOIDCInvalidState
+	}
+
+	meta, err := oidcDiscover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, err := oidcExchangeCode(ctx, meta, code, login.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := oidcVerifyIDToken(ctx, meta, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != login.Nonce {
+		return nil, fmt.Errorf("ID token nonce mismatch")
+	}
+
+	return linkOIDCUser(meta.Issuer, claims)
+}
+
+// linkOIDCUser finds the user for a provider identity, linking or creating
+// one by verified email on first sign-in
+func linkOIDCUser(issuer string, claims *idTokenClaims) (*db.User, error) {
+	var user db.User
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var identity db.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+		if err == nil {
+			return tx.Where("id = ?", identity.UserID).First(&user).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Matching on an unverified address would let anyone who can set
+		// that address at the provider take over the account
+		email := strings.ToLower(strings.TrimSpace(claims.Email))
+		if email == "" || !claims.emailVerified() {
+			return ErrOIDCEmailNotVerified
+		}
+
+		now := time.Now()
+		err = tx.Where("LOWER(email) = ?", email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = db.User{
+				ID:              uuid.NewString(),
+				Email:           email,
+				Name:            claims.Name,
+				EmailVerifiedAt: &now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.EmailVerifiedAt == nil:
+			// Anyone can sign up with an address they do not own and wait
+			// for its owner to sign in here; linking would hand them the
+			// owner's account with their own password still working
+			return ErrOIDCAccountNotVerified
+		}
+
+		return tx.Create(&db.UserIdentity{
+			ID:      uuid.NewString(),
+			UserID:  user.ID,
+			Issuer:  issuer,
+			Subject: claims.Subject,
+			Email:   email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// oidcExchangeCode redeems the authorization code for the ID token
+func oidcExchangeCode(ctx context.Context, meta *oidcMetadata, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {config.AppConfig.OIDC_REDIRECT_URL},
+		"client_id":     {config.AppConfig.OIDC_CLIENT_ID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if secret := config.AppConfig.OIDC_CLIENT_SECRET; secret != "" {
+		req.SetBasicAuth(url.QueryEscape(config.AppConfig.OIDC_CLIENT_ID), url.QueryEscape(secret))
+	}
+
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token request rejected (HTTP %d): %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token response has no id_token")
+	}
+	return body.IDToken, nil
+}
+
+// oidcVerifyIDToken checks the ID token's signature, issuer, audience and expiry
+func oidcVerifyIDToken(ctx context.Context, meta *oidcMetadata, raw string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return oidcSigningKey(ctx, meta, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(config.AppConfig.OIDC_CLIENT_ID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("invalid ID token: no subject")
+	}
+	return claims, nil
+}
+
+// oidcDiscover fetches (or returns the cached) provider metadata
+func oidcDiscover(ctx context.Context) (*oidcMetadata, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	if oidcCache.meta != nil && time.Since(oidcCache.metaAt) < oidcCacheTTL {
+		return oidcCache.meta, nil
+	}
+
+	issuer := strings.TrimRight(config.AppConfig.OIDC_ISSUER, "/")
+	var meta oidcMetadata
+	if err := oidcGetJSON(ctx, issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery: issuer %q does not match %q", meta.Issuer, config.AppConfig.OIDC_ISSUER)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery: incomplete provider metadata")
+	}
+
+	oidcCache.meta, oidcCache.metaAt = &meta, time.Now()
+	return &meta, nil
+}
+
+// oidcSigningKey returns the provider's RSA key with the given ID, refetching
+// the key set when the key is unknown (the provider may have rotated keys)
+func oidcSigningKey(ctx context.Context, meta *oidcMetadata, kid string) (*rsa.PublicKey, error) {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+
+	if oidcCache.keys != nil && time.Since(oidcCache.keysAt) < oidcCacheTTL {
+		if key := lookupKey(oidcCache.keys, kid); key != nil {
+			return key, nil
+		}
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := oidcGetJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.N, "="))
+		e, errE := base64.RawURLEncoding.DecodeString(strings.TrimRight(k.E, "="))
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	oidcCache.keys, oidcCache.keysAt = keys, time.Now()
+
+	if key := lookupKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a key by ID; without an ID only a single key is unambiguous
+func lookupKey(keys map[string]*rsa.PublicKey, kid string) *rsa.PublicKey {
+	if kid == "" && len(keys) == 1 {
+		for _, k := range keys {
+			return k
+		}
+	}
+	return keys[kid]
+}
+
+func oidcGetJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"skillup-backend/config"
+	"skillup-backend/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testClientID = "skillup-test"
+
+// testIssuer is a minimal OpenID Connect provider
+type testIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	issuer    string        // issuer in the discovery document
+	challenge string        // PKCE challenge the token endpoint expects
+	claims    jwt.MapClaims // claims of the ID token it returns
+}
+
+// newTestIssuer starts a provider and configures single sign-on to use it
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.issuer,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "good-code" ||
+			r.PostForm.Get("client_id") != testClientID || r.PostForm.Get("redirect_uri") != config.AppConfig.OIDC_REDIRECT_URL ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, p.claims, "k1")})
+	})
+	p.Server = httptest.NewServer(mux)
+	p.issuer = p.URL
+	t.Cleanup(p.Close)
+
+	prev := config.AppConfig
+	config.AppConfig.OIDC_ISSUER = p.URL
+	config.AppConfig.OIDC_CLIENT_ID = testClientID
+	config.AppConfig.OIDC_REDIRECT_URL = "http://api.test/api/auth/oidc/callback"
+	config.AppConfig.OIDC_SCOPES = "openid email"
+	resetOIDCCache()
+	t.Cleanup(func() {
+		config.AppConfig = prev
+		resetOIDCCache()
+	})
+	return p
+}
+
+func resetOIDCCache() {
+	oidcCache.Lock()
+	defer oidcCache.Unlock()
+	oidcCache.meta, oidcCache.keys = nil, nil
+}
+
+func (p *testIssuer) sign(t *testing.T, claims jwt.MapClaims, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// idClaims returns valid ID token claims for the provider
+func (p *testIssuer) idClaims(subject, email string, verified bool, nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            p.URL,
+		"aud":            testClientID,
+		"sub":            subject,
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          email,
+		"email_verified": verified,
+		"name":           "Test User",
+	}
+}
+
+func TestOIDCDiscover(t *testing.T) {
+	p := newTestIssuer(t)
+	ctx := context.Background()
+
+	meta, err := oidcDiscover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TokenEndpoint != p.URL+"/token" || meta.JWKSURI != p.URL+"/jwks" {
+		t.Errorf("metadata = %+v", meta)
+	}
+
+	// A provider claiming to be another issuer is refused
+	resetOIDCCache()
+	p.issuer = "https://evil.example"
+	if _, err := oidcDiscover(ctx); err == nil {
+		t.Error("discovery accepted a mismatched issuer")
+	}
+}
+
+func TestOIDCExchangeCodePKCE(t *testing.T) {
+	p := newTestIssuer(t)
+	ctx := context.Background()
+	meta, err := oidcDiscover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := "a-long-random-code-verifier"
+	sum := sha256.Sum256([]byte(verifier))
+	p.challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	p.claims = p.idClaims("sub-1", "a@example.com", true, "n")
+
+	if _, err := oidcExchangeCode(ctx, meta, "good-code", verifier); err != nil {
+		t.Errorf("exchange with the right verifier: %v", err)
+	}
+	if _, err := oidcExchangeCode(ctx, meta, "good-code", "another-verifier"); err == nil {
+		t.Error("exchange succeeded with the wrong code verifier")
+	}
+	if _, err := oidcExchangeCode(ctx, meta, "bad-code", verifier); err == nil {
+		t.Error("exchange succeeded with an unknown code")
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	p := newTestIssuer(t)
+	ctx := context.Background()
+	meta, err := oidcDiscover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(changes jwt.MapClaims) jwt.MapClaims {
+		claims := p.idClaims("sub-1", "a@example.com", true, "nonce-1")
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+	tests := []struct {
+		name    string
+		raw     func() string
+		wantErr bool
+	}{
+		{name: "valid", raw: func() string { return p.sign(t, with(nil), "k1") }},
+		{name: "audience list", raw: func() string { return p.sign(t, with(jwt.MapClaims{"aud": []string{"other", testClientID}}), "k1") }},
+		{name: "expired within leeway", raw: func() string {
+			return p.sign(t, with(jwt.MapClaims{"exp": time.Now().Add(-30 * time.Second).Unix()}), "k1")
+		}},
+		{name: "expired", wantErr: true, raw: func() string {
+			return p.sign(t, with(jwt.MapClaims{"exp": time.Now().Add(-5 * time.Minute).Unix()}), "k1")
+		}},
+		{name: "no expiry", wantErr: true, raw: func() string { return p.sign(t, with(jwt.MapClaims{"exp": nil}), "k1") }},
+		{name: "wrong issuer", wantErr: true, raw: func() string { return p.sign(t, with(jwt.MapClaims{"iss": "https://evil.example"}), "k1") }},
+		{name: "wrong audience", wantErr: true, raw: func() string { return p.sign(t, with(jwt.MapClaims{"aud": "another-client"}), "k1") }},
+		{name: "no subject", wantErr: true, raw: func() string { return p.sign(t, with(jwt.MapClaims{"sub": nil}), "k1") }},
+		{name: "unknown key", wantErr: true, raw: func() string { return p.sign(t, with(nil), "k2") }},
+		{name: "signed by another key", wantErr: true, raw: func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, with(nil))
+			token.Header["kid"] = "k1"
+			raw, _ := token.SignedString(otherKey)
+			return raw
+		}},
+		{name: "HMAC", wantErr: true, raw: func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, with(nil))
+			token.Header["kid"] = "k1"
+			raw, _ := token.SignedString([]byte("secret"))
+			return raw
+		}},
+		{name: "unsigned", wantErr: true, raw: func() string {
+			raw, _ := jwt.NewWithClaims(jwt.SigningMethodNone, with(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return raw
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := oidcVerifyIDToken(ctx, meta, tt.raw())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("oidcVerifyIDToken() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.Subject != "sub-1" || claims.Nonce != "nonce-1" || !claims.emailVerified()) {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestOIDCStateBinding(t *testing.T) {
+	binding := OIDCStateBinding("state-1")
+	tests := []struct {
+		name           string
+		binding, state string
+		want           bool
+	}{
+		{"matching", binding, "state-1", true},
+		{"other state", binding, "state-2", false},
+		{"no cookie", "", "state-1", false},
+		{"no state", binding, "", false},
+		{"state as cookie", "state-1", "state-1", false},
+	}
+	for _, tt := range tests {
+		if got := CheckOIDCStateBinding(tt.binding, tt.state); got != tt.want {
+			t.Errorf("%s: CheckOIDCStateBinding() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	useTestDB(t)
+	p := newTestIssuer(t)
+	ctx := context.Background()
+
+	// begin returns the state and records the nonce and PKCE challenge the
+	// provider would have seen
+	begin := func() (state, nonce string) {
+		t.Helper()
+		authURL, state, err := BeginOIDCLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if !strings.HasPrefix(authURL, p.URL+"/authorize?") || q.Get("state") != state ||
+			q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" {
+			t.Fatalf("authorization URL %s", authURL)
+		}
+		p.challenge = q.Get("code_challenge")
+		return state, q.Get("nonce")
+	}
+
+	email := uuid.NewString() + "@example.com"
+	subject := uuid.NewString()
+	state, nonce := begin()
+	p.claims = p.idClaims(subject, email, true, nonce)
+	user, err := CompleteOIDCLogin(ctx, state, "good-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Email != email || user.Password != "" || user.EmailVerifiedAt == nil {
+		t.Errorf("user = %+v, want a verified user without a password", user)
+	}
+
+	// The state is single use
+	if _, err := CompleteOIDCLogin(ctx, state, "good-code"); !errors.Is(err, ErrOIDCInvalidState) {
+		t.Errorf("reused state: error = %v, want ErrOIDCInvalidState", err)
+	}
+
+	// The ID token must carry the nonce of this sign-in
+	state, _ = begin()
+	p.claims = p.idClaims(subject, email, true, "another-nonce")
+	if _, err := CompleteOIDCLogin(ctx, state, "good-code"); err == nil {
+		t.Error("sign-in succeeded with another sign-in's nonce")
+	}
+
+	// Signing in again finds the same user by subject
+	state, nonce = begin()
+	p.claims = p.idClaims(subject, email, true, nonce)
+	again, err := CompleteOIDCLogin(ctx, state, "good-code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second sign-in gave user %s, want %s", again.ID, user.ID)
+	}
+}
+
+func TestLinkOIDCUser(t *testing.T) {
+	useTestDB(t)
+	const issuer = "https://idp.example"
+
+	identities := func(userID string) int64 {
+		t.Helper()
+		var n int64
+		if err := db.DB.Model(&db.UserIdentity{}).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	claims := func(email string, verified bool) *idTokenClaims {
+		c := &idTokenClaims{Email: email, EmailVerified: verified}
+		c.Subject = uuid.NewString()
+		return c
+	}
+
+	t.Run("verified account is linked", func(t *testing.T) {
+		owner := createTestUser(t, "password")
+		if err := db.DB.Model(owner).Update("email_verified_at", time.Now()).Error; err != nil {
+			t.Fatal(err)
+		}
+		user, err := linkOIDCUser(issuer, claims(strings.ToUpper(owner.Email), true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.ID != owner.ID || identities(owner.ID) != 1 {
+			t.Errorf("linked to %s with %d identities, want %s with 1", user.ID, identities(owner.ID), owner.ID)
+		}
+	})
+
+	t.Run("unverified account is not linked", func(t *testing.T) {
+		// Someone signed up with the address but never proved they own it
+		squatter := createTestUser(t, "password")
+		_, err := linkOIDCUser(issuer, claims(squatter.Email, true))
+		if !errors.Is(err, ErrOIDCAccountNotVerified) {
+			t.Fatalf("error = %v, want ErrOIDCAccountNotVerified", err)
+		}
+		if identities(squatter.ID) != 0 || reloadUser(t, squatter.ID).EmailVerifiedAt != nil {
+			t.Error("the unverified account was linked or verified")
+		}
+	})
+
+	t.Run("provider email must be verified", func(t *testing.T) {
+		owner := createTestUser(t, "password")
+		if err := db.DB.Model(owner).Update("email_verified_at", time.Now()).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := linkOIDCUser(issuer, claims(owner.Email, false)); !errors.Is(err, ErrOIDCEmailNotVerified) {
+			t.Errorf("error = %v, want ErrOIDCEmailNotVerified", err)
+		}
+		if _, err := linkOIDCUser(issuer, claims("", true)); !errors.Is(err, ErrOIDCEmailNotVerified) {
+			t.Errorf("no email: error = %v, want ErrOIDCEmailNotVerified", err)
+		}
+		if identities(owner.ID) != 0 {
+			t.Error("an unverified provider email was linked")
+		}
+	})
+
+	t.Run("new email creates a user", func(t *testing.T) {
+		email := uuid.NewString() + "@example.com"
+		user, err := linkOIDCUser(issuer, claims(email, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Email != email || user.Password != "" || user.EmailVerifiedAt == nil || identities(user.ID) != 1 {
+			t.Errorf("user = %+v", user)
+		}
+	})
+}
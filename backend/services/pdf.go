@@ -2,7 +2,9 @@ package services
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/ledongthuc/pdf"
 )
@@ -13,24 +15,41 @@ func ReadAll(r io.Reader) ([]byte, error) {
 	return data, err
 }
 
-// PDFToText extracts text from PDF bytes
-func PDFToText(data []byte) string {
+// ExtractPDF extracts per-page text and document metadata from PDF bytes
+func ExtractPDF(data []byte) (doc *ExtractedDocument, err error) {
+	// The PDF parser panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
 	reader := bytes.NewReader(data)
 	p, err := pdf.NewReader(reader, int64(len(data)))
 	if err != nil {
-		return ""
+		return nil, err
 	}
 
-	var text string
 	num := p.NumPage()
+	doc = &ExtractedDocument{
+		PageCount: num,
+		Pages:     make([]string, num),
+		Paginated: true,
+	}
+
+	info := p.Trailer().Key("Info")
+	if !info.IsNull() {
+		doc.Title = strings.TrimSpace(info.Key("Title").Text())
+		doc.Author = strings.TrimSpace(info.Key("Author").Text())
+	}
+
 	for i := 1; i <= num; i++ {
 		page := p.Page(i)
 		if page.V.IsNull() {
 			continue
 		}
 		str, _ := page.GetPlainText(nil)
-		text += str + "\n"
+		doc.Pages[i-1] = str
 	}
-	return text
+	return doc, nil
 }
-
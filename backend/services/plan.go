@@ -0,0 +1,410 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Plan task types
+const (
+	PlanTaskReading    = "reading"
+	PlanTaskQuiz       = "quiz"
+	PlanTaskFlashcards = "flashcards"
+)
+
+const (
+	// PlanDateLayout is the date format used throughout study plans
+	PlanDateLayout = "2006-01-02"
+
+	minutesPerPage      = 3  // careful study reading, not skimming
+	quizMinutes         = 20 // one quiz on a reading range
+	flashcardMinutes    = 10 // daily review of due cards
+	quizEveryDays       = 7  // quiz long documents at least weekly
+	defaultDailyMinutes = 60
+	maxDailyMinutes     = 720
+	maxPlanDays         = 366
+)
+
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// PlanDocument is a document to cover in a study plan
+type PlanDocument struct {
+	ID       string
+	Filename string
+	Pages    int
+}
+
+// PlanRequest is everything the planner needs to lay out a goal
+type PlanRequest struct {
+	GoalTitle    string
+	Start        time.Time
+	Target       time.Time
+	Availability map[string]int // minutes per weekday, see ParseAvailability
+	Documents    []PlanDocument
+	PagesRead    map[string]map[int]bool // pages already read, carried over on regeneration
+	Flashcards   bool                    // schedule daily flashcard reviews
+	Revision     int
+}
+
+// StudyPlan is the day-by-day plan stored in Goal.AIPlan
+type StudyPlan struct {
+	Overview         string         `json:"overview"`
+	StartDate        string         `json:"start_date"`
+	TargetDate       string         `json:"target_date"`
+	Availability     map[string]int `json:"availability"`
+	Days             []PlanDay      `json:"days"`
+	UnscheduledPages int            `json:"unscheduled_pages"` // pages that did not fit before the target date
+	Revision         int            `json:"revision"`
+}
+
+// PlanDay is one day of a study plan
+type PlanDay struct {
+	Date    string     `json:"date"`
+	Minutes int        `json:"minutes"` // time available that day
+	Tasks   []PlanTask `json:"tasks"`
+}
+
+// PlanTask is one piece of work on a plan day
+type PlanTask struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	DocumentID  string `json:"document_id,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	PageStart   int    `json:"page_start,omitempty"`
+	PageEnd     int    `json:"page_end,omitempty"`
+	Minutes     int    `json:"minutes"`
+	Description string `json:"description"`
+	Done        bool   `json:"done"`
+}
+
+// ParseAvailability validates minutes per weekday ("monday": 60, ...).
+// Missing days get no study time; an empty map means an hour every day.
+func ParseAvailability(in map[string]int) (map[string]int, error) {
+	out := make(map[string]int, len(weekdays))
+	if len(in) == 0 {
+		for _, d := range weekdays {
+			out[d] = defaultDailyMinutes
+		}
+		return out, nil
+	}
+
+	total := 0
+	for day, minutes := range in {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if !containsString(weekdays, day) {
+			return nil, fmt.Errorf("unknown weekday %q", day)
+		}
+		if minutes < 0 || minutes > maxDailyMinutes {
+			return nil, fmt.Errorf("minutes for %s must be between 0 and %d", day, maxDailyMinutes)
+		}
+		out[day] = minutes
+		total += minutes
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("availability must include some study time")
+	}
+	return out, nil
+}
+
+// Behind reports whether a task scheduled before today is still open
+func (p *StudyPlan) Behind(today time.Time) bool {
+	day := today.Format(PlanDateLayout)
+	for _, d := range p.Days {
+		if d.Date >= day {
+			break
+		}
+		for _, t := range d.Tasks {
+			if !t.Done {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetTaskDone marks a task done or not done; false if there is no such task
+func (p *StudyPlan) SetTaskDone(taskID string, done bool) bool {
+	for i := range p.Days {
+		for j := range p.Days[i].Tasks {
+			if p.Days[i].Tasks[j].ID == taskID {
+				p.Days[i].Tasks[j].Done = done
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PagesRead collects the pages of completed reading tasks per document
+func (p *StudyPlan) PagesRead() map[string]map[int]bool {
+	read := map[string]map[int]bool{}
+	for _, d := range p.Days {
+		for _, t := range d.Tasks {
+			if t.Type != PlanTaskReading || !t.Done {
+				continue
+			}
+			if read[t.DocumentID] == nil {
+				read[t.DocumentID] = map[int]bool{}
+			}
+			for page := t.PageStart; page <= t.PageEnd; page++ {
+				read[t.DocumentID][page] = true
+			}
+		}
+	}
+	return read
+}
+
+// GenerateStudyPlan lays out reading, quizzes and flashcard reviews from Start
+// to Target within the daily availability. The LLM orders the documents and
+// writes the overview; the schedule itself is computed so it always fits.
+func GenerateStudyPlan(req PlanRequest) (*StudyPlan, error) {
+	start := truncateDay(req.Start)
+	target := truncateDay(req.Target)
+	if target.Before(start) {
+		return nil, fmt.Errorf("target date is in the past")
+	}
+	if target.Sub(start) > maxPlanDays*24*time.Hour {
+		return nil, fmt.Errorf("plans can cover at most %d days", maxPlanDays)
+	}
+	if len(req.Documents) == 0 {
+		return nil, fmt.Errorf("goal has no documents to plan")
+	}
+
+	// Pages still to read per document
+	var totalPages int
+	remaining := make(map[string][]int, len(req.Documents))
+	for _, d := range req.Documents {
+		for page := 1; page <= d.Pages; page++ {
+			if !req.PagesRead[d.ID][page] {
+				remaining[d.ID] = append(remaining[d.ID], page)
+			}
+		}
+		totalPages += len(remaining[d.ID])
+	}
+
+	days := int(math.Round(target.Sub(start).Hours()/24)) + 1
+	availableMinutes := 0
+	for i := 0; i < days; i++ {
+		availableMinutes += req.Availability[weekdays[start.AddDate(0, 0, i).Weekday()]]
+	}
+
+	order, overview, err := orderPlanDocuments(req, totalPages, days, availableMinutes)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &StudyPlan{
+		Overview:     overview,
+		StartDate:    start.Format(PlanDateLayout),
+		TargetDate:   target.Format(PlanDateLayout),
+		Availability: req.Availability,
+		Revision:     req.Revision,
+	}
+	schedulePlan(plan, req, order, remaining, start, days)
+	return plan, nil
+}
+
+// pendingQuiz is a reading range that has not been quizzed yet
+type pendingQuiz struct {
+	doc      PlanDocument
+	first    int
+	last     int
+	since    int  // day index of the first unquizzed reading
+	finished bool // the document has been read to the end
+}
+
+func schedulePlan(plan *StudyPlan, req PlanRequest, order []PlanDocument, remaining map[string][]int, start time.Time, days int) {
+	taskN := 0
+	newTask := func(t PlanTask) PlanTask {
+		taskN++
+		t.ID = fmt.Sprintf("t%d", taskN)
+		return t
+	}
+
+	di := 0
+	var quizzes []*pendingQuiz
+	quizFor := func(doc PlanDocument, day int) *pendingQuiz {
+		for _, q := range quizzes {
+			if q.doc.ID == doc.ID && !q.finished {
+				return q
+			}
+		}
+		q := &pendingQuiz{doc: doc, first: -1, since: day}
+		quizzes = append(quizzes, q)
+		return q
+	}
+
+	for i := 0; i < days; i++ {
+		date := start.AddDate(0, 0, i)
+		left := req.Availability[weekdays[date.Weekday()]]
+		if left <= 0 {
+			continue
+		}
+		day := PlanDay{Date: date.Format(PlanDateLayout), Minutes: left}
+
+		if req.Flashcards && left >= 2*flashcardMinutes {
+			day.Tasks = append(day.Tasks, newTask(PlanTask{
+				Type:        PlanTaskFlashcards,
+				Minutes:     flashcardMinutes,
+				Description: "Review due flashcards",
+			}))
+			left -= flashcardMinutes
+		}
+
+		// Quiz finished documents, and long ones weekly
+		var open []*pendingQuiz
+		for _, q := range quizzes {
+			due := q.first > 0 && (q.finished || i-q.since >= quizEveryDays)
+			if due && left >= quizMinutes {
+				day.Tasks = append(day.Tasks, newTask(PlanTask{
+					Type:        PlanTaskQuiz,
+					DocumentID:  q.doc.ID,
+					Filename:    q.doc.Filename,
+					PageStart:   q.first,
+					PageEnd:     q.last,
+					Minutes:     quizMinutes,
+					Description: fmt.Sprintf("Quiz on %s, %s", q.doc.Filename, rangeLabel(q.first, q.last)),
+				}))
+				left -= quizMinutes
+				continue
+			}
+			open = append(open, q)
+		}
+		quizzes = open
+
+		// Read as many pages as fit, one task per contiguous range
+		for left >= minutesPerPage && di < len(order) {
+			doc := order[di]
+			pages := remaining[doc.ID]
+			if len(pages) == 0 {
+				di++
+				continue
+			}
+
+			n := 1
+			for n < len(pages) && n < left/minutesPerPage && pages[n] == pages[n-1]+1 {
+				n++
+			}
+			day.Tasks = append(day.Tasks, newTask(PlanTask{
+				Type:        PlanTaskReading,
+				DocumentID:  doc.ID,
+				Filename:    doc.Filename,
+				PageStart:   pages[0],
+				PageEnd:     pages[n-1],
+				Minutes:     n * minutesPerPage,
+				Description: fmt.Sprintf("Read %s, %s", doc.Filename, rangeLabel(pages[0], pages[n-1])),
+			}))
+			left -= n * minutesPerPage
+
+			q := quizFor(doc, i)
+			if q.first < 0 {
+				q.first = pages[0]
+			}
+			q.last = pages[n-1]
+
+			remaining[doc.ID] = pages[n:]
+			if len(remaining[doc.ID]) == 0 {
+				q.finished = true
+				di++
+			}
+		}
+
+		if len(day.Tasks) > 0 {
+			plan.Days = append(plan.Days, day)
+		}
+	}
+
+	for _, pages := range remaining {
+		plan.UnscheduledPages += len(pages)
+	}
+}
+
+// orderPlanDocuments asks the LLM for a sensible reading order and a short
+// overview of the plan. An unusable order falls back to the given one.
+func orderPlanDocuments(req PlanRequest, totalPages, days, availableMinutes int) ([]PlanDocument, string, error) {
+	var list []string
+	for i, d := range req.Documents {
+		list = append(list, fmt.Sprintf("%d. %s (%d pages)", i+1, d.Filename, d.Pages))
+	}
+
+	neededMinutes := totalPages * minutesPerPage
+	prompt := fmt.Sprintf(`You are a study coach. A student has the goal "%s" and must study these documents:
+
+%s
+
+They have %d days and %d minutes of study time in total. Reading the remaining %d pages takes about %d minutes, plus quizzes and flashcard reviews.
+
+Return ONLY a valid JSON object with this exact structure:
+{"order": [2, 1], "overview": "Two or three sentences of advice on how to approach the plan"}
+
+order lists every document number once, foundational material first. If the time is not enough, say so in the overview. Return ONLY the JSON object, no other text.`,
+		req.GoalTitle, strings.Join(list, "\n"), days, availableMinutes, totalPages, neededMinutes)
+
+	response, err := LLM(prompt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate study plan: %w", err)
+	}
+
+	startIdx := strings.Index(response, "{")
+	endIdx := strings.LastIndex(response, "}")
+	if startIdx == -1 || endIdx == -1 || endIdx < startIdx {
+		return nil, "", newLLMError(GetProvider().Name(), ErrLLMBadResponse, "no JSON object in plan response")
+	}
+
+	var out struct {
+		Order    []int  `json:"order"`
+		Overview string `json:"overview"`
+	}
+	if err := json.Unmarshal([]byte(response[startIdx:endIdx+1]), &out); err != nil {
+		return nil, "", newLLMError(GetProvider().Name(), ErrLLMBadResponse, "failed to parse study plan: "+err.Error())
+	}
+
+	order := req.Documents
+	if isPermutation(out.Order, len(req.Documents)) {
+		order = make([]PlanDocument, 0, len(req.Documents))
+		for _, n := range out.Order {
+			order = append(order, req.Documents[n-1])
+		}
+	}
+	return order, strings.TrimSpace(out.Overview), nil
+}
+
+// isPermutation reports whether nums holds each of 1..n exactly once
+func isPermutation(nums []int, n int) bool {
+	if len(nums) != n {
+		return false
+	}
+	sorted := append([]int(nil), nums...)
+	sort.Ints(sorted)
+	for i, v := range sorted {
+		if v != i+1 {
+			return false
+		}
+	}
+	return true
+}
+
+func rangeLabel(first, last int) string {
+	if first == last {
+		return fmt.Sprintf("page %d", first)
+	}
+	return fmt.Sprintf("pages %d-%d", first, last)
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
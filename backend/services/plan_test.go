@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAvailability(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      map[string]int
+		want    map[string]int
+		wantErr bool
+	}{
+		{name: "empty means an hour every day", in: nil, want: map[string]int{
+			"sunday": 60, "monday": 60, "tuesday": 60, "wednesday": 60, "thursday": 60, "friday": 60, "saturday": 60,
+		}},
+		{name: "names are normalized", in: map[string]int{" Monday": 30, "SATURDAY": 90}, want: map[string]int{"monday": 30, "saturday": 90}},
+		{name: "zero days are kept", in: map[string]int{"monday": 45, "tuesday": 0}, want: map[string]int{"monday": 45, "tuesday": 0}},
+		{name: "unknown weekday", in: map[string]int{"mon": 30}, wantErr: true},
+		{name: "negative minutes", in: map[string]int{"monday": -1}, wantErr: true},
+		{name: "too many minutes", in: map[string]int{"monday": maxDailyMinutes + 1}, wantErr: true},
+		{name: "no study time at all", in: map[string]int{"monday": 0, "friday": 0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAvailability(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAvailability() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAvailability() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// pageRange lists pages first..last
+func pageRange(first, last int) []int {
+	var pages []int
+	for p := first; p <= last; p++ {
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+func TestSchedulePlan(t *testing.T) {
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	everyDay := func(minutes int) map[string]int {
+		out := map[string]int{}
+		for _, d := range weekdays {
+			out[d] = minutes
+		}
+		return out
+	}
+
+	tests := []struct {
+		name            string
+		availability    map[string]int
+		flashcards      bool
+		docs            []PlanDocument
+		remaining       map[string][]int
+		days            int
+		want            []string // "date type document first-last"
+		wantUnscheduled int
+	}{
+		{
+			name:         "fits, skipping a zero-availability weekday",
+			availability: map[string]int{"monday": 60, "tuesday": 0, "wednesday": 60},
+			docs:         []PlanDocument{{ID: "a", Pages: 10}},
+			remaining:    map[string][]int{"a": pageRange(1, 10)},
+			days:         3,
+			want: []string{
+				"2026-03-02 reading a 1-10",
+				"2026-03-04 quiz a 1-10",
+			},
+		},
+		{
+			name:            "overflow past the target date",
+			availability:    map[string]int{"monday": 30},
+			docs:            []PlanDocument{{ID: "a", Pages: 20}},
+			remaining:       map[string][]int{"a": pageRange(1, 20)},
+			days:            7,
+			want:            []string{"2026-03-02 reading a 1-10"},
+			wantUnscheduled: 10,
+		},
+		{
+			name:            "no study time at all",
+			availability:    map[string]int{"saturday": 0},
+			docs:            []PlanDocument{{ID: "a", Pages: 3}},
+			remaining:       map[string][]int{"a": pageRange(1, 3)},
+			days:            5,
+			wantUnscheduled: 3,
+		},
+		{
+			name:         "read pages leave gaps, documents in order",
+			availability: map[string]int{"monday": 60},
+			docs:         []PlanDocument{{ID: "a", Pages: 6}, {ID: "b", Pages: 3}},
+			remaining:    map[string][]int{"a": {1, 2, 5, 6}, "b": pageRange(1, 3)},
+			days:         1,
+			want: []string{
+				"2026-03-02 reading a 1-2",
+				"2026-03-02 reading a 5-6",
+				"2026-03-02 reading b 1-3",
+			},
+		},
+		{
+			name:         "flashcards when there is room",
+			availability: map[string]int{"monday": 25, "tuesday": 15},
+			flashcards:   true,
+			docs:         []PlanDocument{{ID: "a", Pages: 10}},
+			remaining:    map[string][]int{"a": pageRange(1, 10)},
+			days:         2,
+			want: []string{
+				"2026-03-02 flashcards  0-0",
+				"2026-03-02 reading a 1-5",
+				"2026-03-03 reading a 6-10",
+			},
+		},
+		{
+			name:         "long documents are quizzed weekly",
+			availability: everyDay(30),
+			docs:         []PlanDocument{{ID: "a", Pages: 100}},
+			remaining:    map[string][]int{"a": pageRange(1, 100)},
+			days:         8,
+			want: []string{
+				"2026-03-02 reading a 1-10",
+				"2026-03-03 reading a 11-20",
+				"2026-03-04 reading a 21-30",
+				"2026-03-05 reading a 31-40",
+				"2026-03-06 reading a 41-50",
+				"2026-03-07 reading a 51-60",
+				"2026-03-08 reading a 61-70",
+				"2026-03-09 quiz a 1-70",
+				"2026-03-09 reading a 71-73",
+			},
+			wantUnscheduled: 27,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &StudyPlan{}
+			req := PlanRequest{Availability: tt.availability, Documents: tt.docs, Flashcards: tt.flashcards}
+			schedulePlan(plan, req, tt.docs, tt.remaining, monday, tt.days)
+
+			var got []string
+			ids := map[string]bool{}
+			for _, d := range plan.Days {
+				used := 0
+				for _, task := range d.Tasks {
+					got = append(got, fmt.Sprintf("%s %s %s %d-%d", d.Date, task.Type, task.DocumentID, task.PageStart, task.PageEnd))
+					used += task.Minutes
+					if ids[task.ID] {
+						t.Errorf("task ID %s is used twice", task.ID)
+					}
+					ids[task.ID] = true
+				}
+				if used > d.Minutes {
+					t.Errorf("%s has %d minutes of tasks, only %d available", d.Date, used, d.Minutes)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tasks = %q, want %q", got, tt.want)
+			}
+			if plan.UnscheduledPages != tt.wantUnscheduled {
+				t.Errorf("unscheduled pages = %d, want %d", plan.UnscheduledPages, tt.wantUnscheduled)
+			}
+		})
+	}
+}
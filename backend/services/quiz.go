@@ -6,39 +6,189 @@ import (
 	"strings"
 )
 
-// Question represents a quiz question
+// Question types
+const (
+	QuestionMultipleChoice = "multiple_choice" // one correct option out of four
+	QuestionTrueFalse      = "true_false"      // options are ["True", "False"]
+	QuestionMultiSelect    = "multi_select"    // several correct options, partial credit
+	QuestionFillBlank      = "fill_blank"      // "___" in the question, matched against accepted answers
+	QuestionShortAnswer    = "short_answer"    // free text graded by the LLM against a rubric
+)
+
+// maxQuizQuestions caps the question mix of one quiz
+const maxQuizQuestions = 30
+
+var questionTypes = map[string]bool{
+	QuestionMultipleChoice: true,
+	QuestionTrueFalse:      true,
+	QuestionMultiSelect:    true,
+	QuestionFillBlank:      true,
+	QuestionShortAnswer:    true,
+}
+
+// Question represents a quiz question. Which answer fields are set depends on Type;
+// questions saved before types existed have no Type and are multiple choice.
 type Question struct {
-	ID            string   `json:"id"`
-	Question      string   `json:"question"`
-	Options       []string `json:"options"`
-	CorrectAnswer int      `json:"correct_answer"`
-	Explanation   string   `json:"explanation"`
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Question        string          `json:"question"`
+	Options         []string        `json:"options,omitempty"`
+	CorrectAnswer   int             `json:"correct_answer"`             // multiple_choice, true_false
+	CorrectAnswers  []int           `json:"correct_answers,omitempty"`  // multi_select
+	AcceptedAnswers []string        `json:"accepted_answers,omitempty"` // fill_blank
+	ModelAnswer     string          `json:"model_answer,omitempty"`     // short_answer
+	Rubric          string          `json:"rubric,omitempty"`           // short_answer
+	Explanation     string          `json:"explanation"`
+	Source          *QuestionSource `json:"source,omitempty"` // chunk the question was generated from
+}
+
+// QuestionSource points a question back to the document text it tests
+type QuestionSource struct {
+	ChunkID   string `json:"chunk_id"`
+	PageStart *int   `json:"page_start,omitempty"`
+	PageEnd   *int   `json:"page_end,omitempty"`
+	StartMs   *int   `json:"start_ms,omitempty"` // transcript time range
+	EndMs     *int   `json:"end_ms,omitempty"`
+	Snippet   string `json:"snippet"`
 }
 
 // UserAnswer represents a user's answer to a question
 type UserAnswer struct {
 	QuestionID string `json:"question_id"`
-	Answer     int    `json:"answer"`
+	Answer     int    `json:"answer"`            // option index for multiple_choice and true_false
+	Answers    []int  `json:"answers,omitempty"` // option indexes for multi_select
+	Text       string `json:"text,omitempty"`    // fill_blank and short_answer
 }
 
 // QuizConfig represents configuration for quiz generation
 type QuizConfig struct {
-	NumQuestions int    `json:"num_questions"`
-	Difficulty   string `json:"difficulty"`
+	NumQuestions int            `json:"num_questions"`
+	Difficulty   string         `json:"difficulty"`
+	Types        map[string]int `json:"types"`     // question count per type; all multiple choice when empty
+	PageFrom     int            `json:"page_from"` // optional page range to quiz on
+	PageTo       int            `json:"page_to"`
+	Topics       []string       `json:"topics"` // optional topics to focus the questions on
 }
 
 // QuizFeedback represents feedback for a quiz question
 type QuizFeedback struct {
-	QuestionID    string `json:"question_id"`
-	Correct       bool   `json:"correct"`
-	CorrectAnswer int    `json:"correct_answer,omitempty"`
-	Explanation   string `json:"explanation,omitempty"`
+	QuestionID     string  `json:"question_id"`
+	Correct        bool    `json:"correct"`
+	Credit         float64 `json:"credit"` // 0-1; fractional for partially correct answers
+	CorrectAnswer  *int    `json:"correct_answer,omitempty"`
+	CorrectAnswers []int   `json:"correct_answers,omitempty"`
+	ExpectedAnswer string  `json:"expected_answer,omitempty"`
+	Feedback       string  `json:"feedback,omitempty"` // grader comments on short answers
+	Explanation    string  `json:"explanation,omitempty"`
+}
+
+// Validate checks the requested question count or mix and sets NumQuestions from the mix
+func (c *QuizConfig) Validate() error {
+	if len(c.Types) == 0 {
+		if c.NumQuestions > maxQuizQuestions {
+			return fmt.Errorf("a quiz can have at most %d questions", maxQuizQuestions)
+		}
+		return nil
+	}
+	total := 0
+	for t, n := range c.Types {
+		if !questionTypes[t] {
+			return fmt.Errorf("unknown question type %q", t)
+		}
+		if n < 0 {
+			return fmt.Errorf("question count for %s must not be negative", t)
+		}
+		total += n
+	}
+	if total == 0 {
+		return fmt.Errorf("types must request at least one question")
+	}
+	if total > maxQuizQuestions {
+		return fmt.Errorf("a quiz can have at most %d questions", maxQuizQuestions)
+	}
+	c.NumQuestions = total
+	return nil
+}
+
+// QuestionType returns the question's type, treating untyped questions as multiple choice
+func (q Question) QuestionType() string {
+	if q.Type == "" {
+		return QuestionMultipleChoice
+	}
+	return q.Type
 }
 
-// GenerateQuizFromDocument generates quiz questions from document text using LLM
-func GenerateQuizFromDocument(text string, config QuizConfig) ([]Question, error) {
-	if text == "" {
-		return nil, fmt.Errorf("document text is empty")
+// valid reports whether a generated question has what its type needs to be graded
+func (q Question) valid() bool {
+	switch q.QuestionType() {
+	case QuestionMultipleChoice:
+		return len(q.Options) == 4 && q.CorrectAnswer >= 0 && q.CorrectAnswer < 4
+	case QuestionTrueFalse:
+		return q.CorrectAnswer == 0 || q.CorrectAnswer == 1
+	case QuestionMultiSelect:
+		if len(q.Options) < 3 || len(q.CorrectAnswers) == 0 {
+			return false
+		}
+		for _, i := range q.CorrectAnswers {
+			if i < 0 || i >= len(q.Options) {
+				return false
+			}
+		}
+		return true
+	case QuestionFillBlank:
+		return len(q.AcceptedAnswers) > 0
+	case QuestionShortAnswer:
+		return q.ModelAnswer != "" || q.Rubric != ""
+	}
+	return false
+}
+
+// questionFormats describes the JSON shape of each question type for the prompt
+var questionFormats = map[string]string{
+	QuestionMultipleChoice: `{"id": "q1", "type": "multiple_choice", "question": "Question text?", "options": ["A", "B", "C", "D"], "correct_answer": 0, "explanation": "Why", "source": "C1"}
+  (exactly 4 options; correct_answer is the index 0-3 of the correct option)`,
+	QuestionTrueFalse: `{"id": "q2", "type": "true_false", "question": "Statement.", "correct_answer": 0, "explanation": "Why", "source": "C1"}
+  (correct_answer is 0 for true, 1 for false)`,
+	QuestionMultiSelect: `{"id": "q3", "type": "multi_select", "question": "Which of the following ...?", "options": ["A", "B", "C", "D", "E"], "correct_answers": [0, 2], "explanation": "Why", "source": "C1"}
+  (4-6 options; correct_answers lists every correct index)`,
+	QuestionFillBlank: `{"id": "q4", "type": "fill_blank", "question": "The ___ is ...", "accepted_answers": ["answer", "alternative spelling"], "explanation": "Why", "source": "C1"}
+  (one blank written as ___; the answer is a word or short phrase)`,
+	QuestionShortAnswer: `{"id": "q5", "type": "short_answer", "question": "Explain ...", "model_answer": "A complete answer", "rubric": "What a full-credit answer must mention", "explanation": "Why", "source": "C1"}`,
+}
+
+// questionCounts returns how many questions of each type the quiz asks for
+func questionCounts(config QuizConfig) map[string]int {
+	if len(config.Types) == 0 {
+		return map[string]int{QuestionMultipleChoice: config.NumQuestions}
+	}
+	counts := make(map[string]int, len(config.Types))
+	for t, n := range config.Types {
+		counts[t] = n
+	}
+	return counts
+}
+
+// questionMix returns the prompt lines describing how many questions of each type to write
+func questionMix(config QuizConfig) (mix, formats string) {
+	types := questionCounts(config)
+
+	var mixLines, formatLines []string
+	for _, t := range []string{QuestionMultipleChoice, QuestionTrueFalse, QuestionMultiSelect, QuestionFillBlank, QuestionShortAnswer} {
+		if types[t] > 0 {
+			mixLines = append(mixLines, fmt.Sprintf("- %d %s", types[t], t))
+			formatLines = append(formatLines, questionFormats[t])
+		}
+	}
+	return strings.Join(mixLines, "\n"), strings.Join(formatLines, "\n")
+}
+
+// GenerateQuizFromDocument generates quiz questions from selected document
+// chunks (see SelectQuizChunks) using LLM. Questions that repeat one of the
+// previous questions are dropped, as are questions of a type that was not
+// requested or beyond the number requested of their type.
+func GenerateQuizFromDocument(chunks []QuizChunk, previous []Question, config QuizConfig) ([]Question, error) {
+	if len(chunks) == 0 {
+		return nil, ErrNoQuizMaterial
 	}
 
 	// Default configuration
@@ -49,98 +199,116 @@ func GenerateQuizFromDocument(text string, config QuizConfig) ([]Question, error
 		config.Difficulty = "medium"
 	}
 
-	// Truncate text if too long (to fit in LLM context)
-	maxChars := 8000
-	if len(text) > maxChars {
-		text = text[:maxChars] + "..."
+	var excerpts []string
+	for i, c := range chunks {
+		label := fmt.Sprintf("[C%d]", i+1)
+		if loc := locationLabel(c.PageStart, c.PageEnd, c.StartMs, c.EndMs); loc != "" {
+			label += " (" + loc + ")"
+		}
+		excerpts = append(excerpts, label+"\n"+c.Text)
 	}
 
-	prompt := fmt.Sprintf(`You are a quiz generator. Generate %d multiple-choice questions from the following text.
+	var avoid string
+	if len(previous) > 0 {
+		var lines []string
+		for i, q := range previous {
+			if i == maxPreviousQuestions {
+				break
+			}
+			lines = append(lines, "- "+q.Question)
+		}
+		avoid = fmt.Sprintf("\nThese questions were already asked; do not repeat or rephrase them:\n%s\n", strings.Join(lines, "\n"))
+	}
 
-Difficulty: %s
+	var focus string
+	if len(config.Topics) > 0 {
+		focus = fmt.Sprintf("\nFocus on these topics: %s\n", strings.Join(config.Topics, ", "))
+	}
+
+	mix, formats := questionMix(config)
 
-Text:
+	prompt := fmt.Sprintf(`You are a quiz generator. Generate %d questions from the following excerpts of a document. Spread the questions across the excerpts.
+
+Question mix:
 %s
 
-Return ONLY a valid JSON array of questions with this exact structure:
-[
-  {
-    "id": "q1",
-    "question": "Question text here?",
-    "options": ["Option A", "Option B", "Option C", "Option D"],
-    "correct_answer": 0,
-    "explanation": "Brief explanation of why this is correct"
-  }
-]
+Difficulty: %s
+%s%s
+Excerpts:
+%s
+
+Return ONLY a valid JSON array of questions. Each question has one of these structures:
+%s
 
 Requirements:
-- Each question must have exactly 4 options
-- correct_answer is the index (0-3) of the correct option
+- "type" must be set on every question
+- source is the tag of the excerpt the question is based on
+- Where an explanation says where to find the answer, use the page or timestamp shown next to the excerpt tag
 - Questions should test understanding, not just memorization
-- Return ONLY the JSON array, no other text`, config.NumQuestions, config.Difficulty, text)
+- Return ONLY the JSON array, no other text`, config.NumQuestions, mix, config.Difficulty, focus, avoid, strings.Join(excerpts, "\n\n"), formats)
 
-	response := LLM(prompt)
+	response, err := LLM(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate quiz: %w", err)
+	}
 
 	// Try to extract JSON from response
 	response = strings.TrimSpace(response)
-	
+
 	// Find JSON array in response
 	startIdx := strings.Index(response, "[")
 	endIdx := strings.LastIndex(response, "]")
-	
-	if startIdx == -1 || endIdx == -1 {
-		return nil, fmt.Errorf("failed to parse quiz questions from LLM response")
-	}
-	
-	jsonStr := response[startIdx : endIdx+1]
 
-	var questions []Question
-	if err := json.Unmarshal([]byte(jsonStr), &questions); err != nil {
-		return nil, fmt.Errorf("failed to parse quiz questions: %v", err)
+	if startIdx == -1 || endIdx == -1 || endIdx < startIdx {
+		return nil, newLLMError(GetProvider().Name(), ErrLLMBadResponse, "no JSON array in quiz response")
 	}
 
-	if len(questions) == 0 {
-		return nil, fmt.Errorf("no questions generated")
-	}
-
-	return questions, nil
-}
+	jsonStr := response[startIdx : endIdx+1]
 
-// CalculateQuizScore calculates the score based on user answers
-func CalculateQuizScore(questions []Question, userAnswers []UserAnswer) (float64, []QuizFeedback) {
-	if len(questions) == 0 {
-		return 0, nil
+	// "source" comes back as an excerpt tag and is resolved to the chunk below
+	var generated []struct {
+		Question
+		Source string `json:"source"`
 	}
-
-	// Create map for quick lookup
-	answerMap := make(map[string]int)
-	for _, ua := range userAnswers {
-		answerMap[ua.QuestionID] = ua.Answer
+	if err := json.Unmarshal([]byte(jsonStr), &generated); err != nil {
+		return nil, newLLMError(GetProvider().Name(), ErrLLMBadResponse, "failed to parse quiz questions: "+err.Error())
 	}
 
-	correct := 0
-	feedback := make([]QuizFeedback, 0, len(questions))
+	seen := map[string]bool{}
+	for _, q := range previous {
+		seen[normalizeText(q.Question)] = true
+	}
 
-	for _, q := range questions {
-		userAns, answered := answerMap[q.ID]
-		
-		fb := QuizFeedback{
-			QuestionID: q.ID,
+	remaining := questionCounts(config)
+	questions := make([]Question, 0, len(generated))
+	for _, g := range generated {
+		key := normalizeText(g.Question.Question)
+		if key == "" || seen[key] || !g.Question.valid() || remaining[g.Question.QuestionType()] <= 0 {
+			continue
 		}
+		seen[key] = true
 
-		if answered && userAns == q.CorrectAnswer {
-			fb.Correct = true
-			correct++
-		} else {
-			fb.Correct = false
-			fb.CorrectAnswer = q.CorrectAnswer
-			fb.Explanation = q.Explanation
+		q := g.Question
+		q.ID = fmt.Sprintf("q%d", len(questions)+1)
+		q.Type = q.QuestionType()
+		remaining[q.Type]--
+		if q.Type == QuestionTrueFalse {
+			q.Options = []string{"True", "False"}
+		}
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimSpace(g.Source), "C%d", &n); err == nil && n >= 1 && n <= len(chunks) {
+			c := chunks[n-1]
+			q.Source = &QuestionSource{ChunkID: c.ChunkID, PageStart: c.PageStart, PageEnd: c.PageEnd, StartMs: c.StartMs, EndMs: c.EndMs, Snippet: snippet(c.Text)}
 		}
+		questions = append(questions, q)
+		if len(questions) == config.NumQuestions {
+			break
+		}
+	}
 
-		feedback = append(feedback, fb)
+	if len(questions) == 0 {
+		return nil, fmt.Errorf("no questions generated")
 	}
 
-	score := (float64(correct) / float64(len(questions))) * 100.0
-	return score, feedback
+	return questions, nil
 }
-
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// shortAnswerPass is the credit at which a short answer counts as correct
+const shortAnswerPass = 0.7
+
+// CalculateQuizScore calculates the score (0-100) based on user answers.
+// Multi-select questions earn partial credit and short answers are graded by
+// the LLM, which is the only source of errors.
+func CalculateQuizScore(questions []Question, userAnswers []UserAnswer) (float64, []QuizFeedback, error) {
+	if len(questions) == 0 {
+		return 0, nil, nil
+	}
+
+	// Create map for quick lookup
+	answerMap := make(map[string]UserAnswer)
+	for _, ua := range userAnswers {
+		answerMap[ua.QuestionID] = ua
+	}
+
+	graded, err := gradeShortAnswers(questions, answerMap)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	total := 0.0
+	feedback := make([]QuizFeedback, 0, len(questions))
+
+	for _, q := range questions {
+		userAns, answered := answerMap[q.ID]
+
+		fb := QuizFeedback{
+			QuestionID: q.ID,
+		}
+
+		if answered {
+			switch q.QuestionType() {
+			case QuestionMultipleChoice, QuestionTrueFalse:
+				if userAns.Answer == q.CorrectAnswer {
+					fb.Credit = 1
+				}
+			case QuestionMultiSelect:
+				fb.Credit = multiSelectCredit(q.CorrectAnswers, userAns.Answers)
+			case QuestionFillBlank:
+				if matchesAccepted(userAns.Text, q.AcceptedAnswers) {
+					fb.Credit = 1
+				}
+			case QuestionShortAnswer:
+				g := graded[q.ID]
+				fb.Credit = g.Score
+				fb.Feedback = g.Feedback
+			}
+		}
+
+		fb.Correct = fb.Credit >= 1 || (q.QuestionType() == QuestionShortAnswer && fb.Credit >= shortAnswerPass)
+		if !fb.Correct {
+			switch q.QuestionType() {
+			case QuestionMultipleChoice, QuestionTrueFalse:
+				correctAnswer := q.CorrectAnswer
+				fb.CorrectAnswer = &correctAnswer
+			case QuestionMultiSelect:
+				fb.CorrectAnswers = q.CorrectAnswers
+			case QuestionFillBlank:
+				fb.ExpectedAnswer = q.AcceptedAnswers[0]
+			case QuestionShortAnswer:
+				fb.ExpectedAnswer = q.ModelAnswer
+			}
+			fb.Explanation = q.Explanation
+		}
+
+		total += fb.Credit
+		feedback = append(feedback, fb)
+	}
+
+	score := (total / float64(len(questions))) * 100.0
+	return score, feedback, nil
+}
+
+// multiSelectCredit gives a share of credit per correct option chosen, minus
+// the same share per wrong option, so selecting everything earns nothing
+func multiSelectCredit(correct, chosen []int) float64 {
+	if len(correct) == 0 {
+		return 0
+	}
+	isCorrect := map[int]bool{}
+	for _, i := range correct {
+		isCorrect[i] = true
+	}
+
+	hits, misses := 0, 0
+	seen := map[int]bool{}
+	for _, i := range chosen {
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		if isCorrect[i] {
+			hits++
+		} else {
+			misses++
+		}
+	}
+
+	credit := float64(hits-misses) / float64(len(isCorrect))
+	if credit < 0 {
+		return 0
+	}
+	return credit
+}
+
+// matchesAccepted compares a fill-in-the-blank answer ignoring case,
+// punctuation, extra whitespace and a leading article
+func matchesAccepted(answer string, accepted []string) bool {
+	got := stripArticle(normalizeText(answer))
+	if got == "" {
+		return false
+	}
+	for _, a := range accepted {
+		if stripArticle(normalizeText(a)) == got {
+			return true
+		}
+	}
+	return false
+}
+
+func stripArticle(s string) string {
+	for _, article := range []string{"the ", "a ", "an "} {
+		if strings.HasPrefix(s, article) {
+			return s[len(article):]
+		}
+	}
+	return s
+}
+
+// normalizeText reduces text to lowercase words of letters and digits
+func normalizeText(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// shortAnswerGrade is the LLM's verdict on one short answer
+type shortAnswerGrade struct {
+	ID       string  `json:"id"`
+	Score    float64 `json:"score"`
+	Feedback string  `json:"feedback"`
+}
+
+// gradeShortAnswers grades every answered short-answer question in one LLM call
+func gradeShortAnswers(questions []Question, answers map[string]UserAnswer) (map[string]shortAnswerGrade, error) {
+	var items []string
+	for _, q := range questions {
+		if q.QuestionType() != QuestionShortAnswer {
+			continue
+		}
+		ua, ok := answers[q.ID]
+		if !ok || strings.TrimSpace(ua.Text) == "" {
+			continue
+		}
+		items = append(items, fmt.Sprintf("ID: %s\nQuestion: %s\nModel answer: %s\nRubric: %s\nStudent answer: %s",
+			q.ID, q.Question, q.ModelAnswer, q.Rubric, ua.Text))
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	prompt := fmt.Sprintf(`You are grading a student's short answers. Grade each answer against its rubric and model answer. Judge the meaning, not the wording, and ignore spelling mistakes.
+
+%s
+
+Return ONLY a valid JSON array with one entry per answer:
+[
+  {"id": "q1", "score": 0.5, "feedback": "One sentence on what was right or missing"}
+]
+
+score is between 0 (wrong or missing) and 1 (fully correct). Return ONLY the JSON array, no other text.`, strings.Join(items, "\n\n---\n\n"))
+
+	response, err := LLM(prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grade short answers: %w", err)
+	}
+
+	startIdx := strings.Index(response, "[")
+	endIdx := strings.LastIndex(response, "]")
+	if startIdx == -1 || endIdx == -1 || endIdx < startIdx {
+		return nil, newLLMError(GetProvider().Name(), ErrLLMBadResponse, "no JSON array in grading response")
+	}
+
+	var grades []shortAnswerGrade
+	if err := json.Unmarshal([]byte(response[startIdx:endIdx+1]), &grades); err != nil {
+		return nil, newLLMError(GetProvider().Name(), ErrLLMBadResponse, "failed to parse grades: "+err.Error())
+	}
+
+	byID := make(map[string]shortAnswerGrade, len(grades))
+	for _, g := range grades {
+		if g.Score < 0 {
+			g.Score = 0
+		}
+		if g.Score > 1 {
+			g.Score = 1
+		}
+		byID[g.ID] = g
+	}
+	return byID, nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMultiSelectCredit(t *testing.T) {
+	tests := []struct {
+		name    string
+		correct []int
+		chosen  []int
+		want    float64
+	}{
+		{"all correct", []int{0, 2}, []int{2, 0}, 1},
+		{"one of two", []int{0, 2}, []int{0}, 0.5},
+		{"one of three", []int{0, 1, 3}, []int{3}, 1.0 / 3},
+		{"right and wrong cancel", []int{0, 2}, []int{0, 1}, 0},
+		{"two right one wrong", []int{0, 1, 2}, []int{0, 1, 3}, 1.0 / 3},
+		{"everything earns nothing", []int{0, 1}, []int{0, 1, 2, 3}, 0},
+		{"only wrong clamps at zero", []int{0}, []int{1, 2}, 0},
+		{"duplicates count once", []int{0, 2}, []int{0, 0, 0}, 0.5},
+		{"nothing chosen", []int{1}, nil, 0},
+		{"no correct options", nil, []int{1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := multiSelectCredit(tt.correct, tt.chosen); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("multiSelectCredit(%v, %v) = %v, want %v", tt.correct, tt.chosen, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesAccepted(t *testing.T) {
+	accepted := []string{"the mitochondria", "Mitochondrion"}
+	tests := []struct {
+		answer string
+		want   bool
+	}{
+		{"mitochondria", true},
+		{"  The   Mitochondria. ", true},
+		{"a mitochondrion!", true},
+		{"MITOCHONDRION", true},
+		{"an mitochondria", true},
+		{"chloroplast", false},
+		{"mitochondria membrane", false},
+		{"", false},
+		{"the", false},
+		{"...", false},
+	}
+	for _, tt := range tests {
+		if got := matchesAccepted(tt.answer, accepted); got != tt.want {
+			t.Errorf("matchesAccepted(%q) = %v, want %v", tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateQuizScore(t *testing.T) {
+	questions := []Question{
+		{ID: "q1", Question: "Legacy untyped", Options: []string{"a", "b", "c", "d"}, CorrectAnswer: 2},
+		{ID: "q2", Type: QuestionTrueFalse, CorrectAnswer: 1},
+		{ID: "q3", Type: QuestionMultiSelect, CorrectAnswers: []int{0, 1}},
+		{ID: "q4", Type: QuestionFillBlank, AcceptedAnswers: []string{"ATP"}},
+		{ID: "q5", Type: QuestionMultipleChoice, CorrectAnswer: 0},
+	}
+	answers := []UserAnswer{
+		{QuestionID: "q1", Answer: 2},
+		{QuestionID: "q2", Answer: 0},
+		{QuestionID: "q3", Answers: []int{0, 3}, Answer: 0},
+		{QuestionID: "q4", Text: "atp"},
+		// q5 unanswered
+	}
+
+	score, feedback, err := CalculateQuizScore(questions, answers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 + 0 + 0 (one right, one wrong pick) + 1 + 0 out of 5
+	if math.Abs(score-40) > 1e-9 {
+		t.Errorf("score = %v, want 40", score)
+	}
+
+	wantCorrect := map[string]bool{"q1": true, "q2": false, "q3": false, "q4": true, "q5": false}
+	for _, fb := range feedback {
+		if fb.Correct != wantCorrect[fb.QuestionID] {
+			t.Errorf("%s correct = %v, want %v", fb.QuestionID, fb.Correct, wantCorrect[fb.QuestionID])
+		}
+	}
+	if fb := feedback[1]; fb.CorrectAnswer == nil || *fb.CorrectAnswer != 1 {
+		t.Errorf("q2 feedback correct answer = %v, want 1", fb.CorrectAnswer)
+	}
+	if fb := feedback[2]; len(fb.CorrectAnswers) != 2 {
+		t.Errorf("q3 feedback correct answers = %v, want [0 1]", fb.CorrectAnswers)
+	}
+	if fb := feedback[0]; fb.CorrectAnswer != nil {
+		t.Errorf("q1 is correct but feedback reveals %v", *fb.CorrectAnswer)
+	}
+}
+
+func TestCalculateQuizScorePartialCredit(t *testing.T) {
+	questions := []Question{{ID: "q1", Type: QuestionMultiSelect, CorrectAnswers: []int{0, 1, 2, 3}}}
+	score, feedback, err := CalculateQuizScore(questions, []UserAnswer{{QuestionID: "q1", Answers: []int{0, 1, 2}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(score-75) > 1e-9 {
+		t.Errorf("score = %v, want 75", score)
+	}
+	if feedback[0].Correct || math.Abs(feedback[0].Credit-0.75) > 1e-9 {
+		t.Errorf("feedback = %+v, want incorrect with credit 0.75", feedback[0])
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"skillup-backend/db"
+	"strings"
+)
+
+const (
+	// quizContextChars caps the document text sent to the quiz generator
+	quizContextChars = 12000
+	// minQuizChunkChars keeps each excerpt long enough to ask about
+	minQuizChunkChars = 600
+	// maxPreviousQuestions bounds how many earlier questions go into the prompt
+	maxPreviousQuestions = 40
+)
+
+// ErrNoQuizMaterial means the document (or requested page range) has no chunks
+var ErrNoQuizMaterial = errors.New("no document content to generate questions from")
+
+// QuizChunk is a document chunk selected as quiz material
+type QuizChunk struct {
+	ChunkID    string
+	ChunkIndex int
+	Text       string
+	PageStart  *int
+	PageEnd    *int
+	StartMs    *int
+	EndMs      *int
+}
+
+// PreviousQuizQuestions returns the questions of the user's earlier quizzes on a document
+func PreviousQuizQuestions(userID, documentID string) ([]Question, error) {
+	var quizzes []db.Quiz
+	if err := db.DB.Where("user_id = ? AND document_id = ?", userID, documentID).
+		Order("created_at desc").Find(&quizzes).Error; err != nil {
+		return nil, err
+	}
+
+	var questions []Question
+	for _, q := range quizzes {
+		var qs []Question
+		if err := json.Unmarshal(q.Questions, &qs); err != nil {
+			continue // skip malformed quizzes rather than failing generation
+		}
+		questions = append(questions, qs...)
+	}
+	return questions, nil
+}
+
+// SelectQuizChunks picks the chunks a quiz is generated from. With topics,
+// the chunks most relevant to them are used; otherwise one chunk is sampled
+// from each of evenly sized strata across the document (or the requested page
+// range). Chunks no earlier question came from are preferred.
+func SelectQuizChunks(userID, documentID string, config QuizConfig, previous []Question) ([]QuizChunk, error) {
+	query := db.DB.Model(&db.DocumentChunk{}).
+		Select("id AS chunk_id, chunk_index, chunk_text AS text, page_start, page_end, start_ms, end_ms").
+		Where("document_id = ? AND user_id = ?", documentID, userID)
+	if config.PageFrom > 0 {
+		query = query.Where("page_end >= ?", config.PageFrom)
+	}
+	if config.PageTo > 0 {
+		query = query.Where("page_start <= ?", config.PageTo)
+	}
+
+	var candidates []QuizChunk
+	if err := query.Order("chunk_index").Scan(&candidates).Error; err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoQuizMaterial
+	}
+
+	used := map[string]bool{}
+	for _, q := range previous {
+		if q.Source != nil {
+			used[q.Source.ChunkID] = true
+		}
+	}
+
+	n := config.NumQuestions
+	if n <= 0 {
+		n = 10
+	}
+	if max := quizContextChars / minQuizChunkChars; n > max {
+		n = max
+	}
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+
+	var picked []QuizChunk
+	if len(config.Topics) > 0 {
+		var err error
+		if picked, err = topicChunks(userID, documentID, config.Topics, candidates, used, n); err != nil {
+			return nil, err
+		}
+	}
+	if len(picked) == 0 {
+		picked = stratifiedChunks(candidates, used, n)
+	}
+
+	// Share the context budget between the excerpts
+	perChunk := quizContextChars / len(picked)
+	for i := range picked {
+		if r := []rune(picked[i].Text); len(r) > perChunk {
+			picked[i].Text = string(r[:perChunk]) + "…"
+		}
+	}
+	return picked, nil
+}
+
+// stratifiedChunks splits the candidates into n contiguous strata and draws one
+// chunk from each, so questions cover the whole range rather than its start
+func stratifiedChunks(candidates []QuizChunk, used map[string]bool, n int) []QuizChunk {
+	picked := make([]QuizChunk, 0, n)
+	for s := 0; s < n; s++ {
+		stratum := candidates[s*len(candidates)/n : (s+1)*len(candidates)/n]
+
+		var fresh []QuizChunk
+		for _, c := range stratum {
+			if !used[c.ChunkID] {
+				fresh = append(fresh, c)
+			}
+		}
+		if len(fresh) > 0 {
+			stratum = fresh
+		}
+		picked = append(picked, stratum[rand.Intn(len(stratum))])
+	}
+	return picked
+}
+
+// topicChunks takes the best matches for each topic in turn, limited to the candidates
+func topicChunks(userID, documentID string, topics []string, candidates []QuizChunk, used map[string]bool, n int) ([]QuizChunk, error) {
+	byID := make(map[string]QuizChunk, len(candidates))
+	for _, c := range candidates {
+		byID[c.ChunkID] = c
+	}
+
+	var ranked [][]QuizChunk
+	for _, topic := range topics {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		emb, err := GetEmbedding(topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed topic: %w", err)
+		}
+		hits, err := HybridSearch(userID, topic, emb, SearchOptions{TopK: maxTopK, DocumentIDs: []string{documentID}})
+		if err != nil {
+			return nil, err
+		}
+
+		// unused chunks first, each group in relevance order
+		var fresh, seen []QuizChunk
+		for _, h := range hits {
+			if c, ok := byID[h.ChunkID]; ok {
+				if used[c.ChunkID] {
+					seen = append(seen, c)
+				} else {
+					fresh = append(fresh, c)
+				}
+			}
+		}
+		ranked = append(ranked, append(fresh, seen...))
+	}
+
+	// round-robin across topics
+	var picked []QuizChunk
+	taken := map[string]bool{}
+	for depth := 0; len(picked) < n; depth++ {
+		progressed := false
+		for _, list := range ranked {
+			if depth < len(list) && len(picked) < n && !taken[list[depth].ChunkID] {
+				taken[list[depth].ChunkID] = true
+				picked = append(picked, list[depth])
+			}
+			if depth < len(list) {
+				progressed = true
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return picked, nil
+}
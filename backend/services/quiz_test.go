@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQuizConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  QuizConfig
+		want    int // NumQuestions afterwards
+		wantErr bool
+	}{
+		{name: "defaults", config: QuizConfig{}, want: 0},
+		{name: "count only", config: QuizConfig{NumQuestions: 12}, want: 12},
+		{name: "count at the cap", config: QuizConfig{NumQuestions: maxQuizQuestions}, want: maxQuizQuestions},
+		{name: "count over the cap", config: QuizConfig{NumQuestions: maxQuizQuestions + 1}, wantErr: true},
+		{
+			name:   "mix sets the count",
+			config: QuizConfig{NumQuestions: 50, Types: map[string]int{QuestionTrueFalse: 3, QuestionShortAnswer: 2}},
+			want:   5,
+		},
+		{name: "mix over the cap", config: QuizConfig{Types: map[string]int{QuestionTrueFalse: 20, QuestionFillBlank: 11}}, wantErr: true},
+		{name: "unknown type", config: QuizConfig{Types: map[string]int{"essay": 2}}, wantErr: true},
+		{name: "negative count in mix", config: QuizConfig{Types: map[string]int{QuestionTrueFalse: -1, QuestionFillBlank: 3}}, wantErr: true},
+		{name: "empty mix", config: QuizConfig{Types: map[string]int{QuestionTrueFalse: 0}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		c := tt.config
+		err := c.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && c.NumQuestions != tt.want {
+			t.Errorf("%s: NumQuestions = %d, want %d", tt.name, c.NumQuestions, tt.want)
+		}
+	}
+}
+
+// fakeProvider answers every prompt with a fixed response
+type fakeProvider struct{ response string }
+
+func (p fakeProvider) Name() string { return "fake" }
+
+func (p fakeProvider) Generate(ctx context.Context, prompt string, opts GenerateOptions) (string, error) {
+	return p.response, nil
+}
+
+func (p fakeProvider) Embed(ctx context.Context, input string) ([]float32, error) {
+	return nil, nil
+}
+
+func useFakeProvider(t *testing.T, response string) {
+	t.Helper()
+	InitLLM()
+	prev := llmProvider
+	llmProvider = fakeProvider{response: response}
+	t.Cleanup(func() { llmProvider = prev })
+}
+
+func TestGenerateQuizKeepsRequestedTypes(t *testing.T) {
+	useFakeProvider(t, `[
+		{"type": "true_false", "question": "The sky is blue.", "correct_answer": 0, "source": "C1"},
+		{"type": "multiple_choice", "question": "Which colour?", "options": ["a", "b", "c", "d"], "correct_answer": 1, "source": "C1"},
+		{"type": "true_false", "question": "Grass is red.", "correct_answer": 1, "source": "C1"},
+		{"type": "true_false", "question": "Water is wet.", "correct_answer": 0, "source": "C1"},
+		{"type": "fill_blank", "question": "The ___ is blue.", "accepted_answers": ["sky"], "source": "C1"},
+		{"question": "Untyped?", "options": ["a", "b", "c", "d"], "correct_answer": 0, "source": "C1"}
+	]`)
+
+	config := QuizConfig{Types: map[string]int{QuestionTrueFalse: 2, QuestionFillBlank: 1}}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	questions, err := GenerateQuizFromDocument([]QuizChunk{{ChunkID: "c1", Text: "The sky is blue."}}, nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, q := range questions {
+		got = append(got, q.Type+": "+q.Question)
+	}
+	want := []string{"true_false: The sky is blue.", "true_false: Grass is red.", "fill_blank: The ___ is blue."}
+	if len(got) != len(want) {
+		t.Fatalf("questions = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("question %d = %q, want %q", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestGenerateQuizDefaultsToMultipleChoice(t *testing.T) {
+	useFakeProvider(t, `[
+		{"type": "true_false", "question": "The sky is blue.", "correct_answer": 0, "source": "C1"},
+		{"question": "Which colour?", "options": ["a", "b", "c", "d"], "correct_answer": 1, "source": "C1"}
+	]`)
+
+	questions, err := GenerateQuizFromDocument([]QuizChunk{{ChunkID: "c1", Text: "The sky is blue."}}, nil, QuizConfig{NumQuestions: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 1 || questions[0].Type != QuestionMultipleChoice {
+		t.Errorf("questions = %+v, want only the multiple choice question", questions)
+	}
+}
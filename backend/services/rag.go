@@ -1,32 +1,85 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"skillup-backend/db"
 	"strings"
 )
 
-// BuildContextFromChunks combines document chunks into context string
-func BuildContextFromChunks(chunks []db.DocumentChunk) string {
-	var parts []string
-	for _, c := range chunks {
-		parts = append(parts, c.ChunkText)
-	}
-	return strings.Join(parts, "\n\n---\n\n")
+// historyAnswerChars caps how much of each earlier answer goes back into prompts
+const historyAnswerChars = 1000
+
+// ChatTurn is one earlier question/answer exchange in a conversation
+type ChatTurn struct {
+	Question string
+	Answer   string
 }
 
 // RAGAnswer generates answer using RAG (Retrieval Augmented Generation)
-func RAGAnswer(question, context string) string {
-	prompt := fmt.Sprintf(`You are a helpful study assistant. Use ONLY the context below (do not hallucinate).
+func RAGAnswer(question, context string, history []ChatTurn) (string, error) {
+	return LLM(buildRAGPrompt(question, context, history))
+}
 
-Context:
+// RAGAnswerStream is RAGAnswer delivered token by token through onToken
+func RAGAnswerStream(ctx context.Context, question, context string, history []ChatTurn, onToken func(string) error) (string, error) {
+	return LLMStream(ctx, buildRAGPrompt(question, context, history), onToken)
+}
+
+// RewriteFollowUp turns a follow-up like "explain that more simply" into a
+// standalone question suitable for embedding. Without history the question
+// is returned unchanged.
+func RewriteFollowUp(history []ChatTurn, question string) (string, error) {
+	if len(history) == 0 {
+		return question, nil
+	}
 
+	prompt := fmt.Sprintf(`Rewrite the follow-up question below as a single standalone question that can be understood without the conversation. Resolve pronouns and references like "that" or "the second one" using the conversation. If it is already standalone, return it unchanged.
+
+Conversation:
 %s
 
-Question: %s
+Follow-up question: %s
+
+Return ONLY the rewritten question, no other text.`, formatHistory(history), question)
 
-Answer concisely. If sources are relevant, mention them.`, context, question)
+	rewritten, err := LLM(prompt)
+	if err != nil {
+		return "", err
+	}
 
-	return LLM(prompt)
+	rewritten = strings.Trim(strings.TrimSpace(rewritten), `"`)
+	if rewritten == "" {
+		return question, nil
+	}
+	return rewritten, nil
 }
 
+func buildRAGPrompt(question, context string, history []ChatTurn) string {
+	var conversation string
+	if len(history) > 0 {
+		conversation = fmt.Sprintf("Conversation so far:\n\n%s\n\n", formatHistory(history))
+	}
+
+	return fmt.Sprintf(`You are a helpful study assistant. Use ONLY the numbered sources below (do not hallucinate).
+
+Sources:
+
+%s
+
+%sQuestion: %s
+
+Answer concisely. Cite the sources you use inline with their numbers in square brackets, e.g. [1] or [2, 3]. If the sources do not contain the answer, say so.`, context, conversation, question)
+}
+
+// formatHistory renders earlier turns oldest first
+func formatHistory(history []ChatTurn) string {
+	var sb strings.Builder
+	for _, t := range history {
+		answer := []rune(t.Answer)
+		if len(answer) > historyAnswerChars {
+			answer = append(answer[:historyAnswerChars], '…')
+		}
+		fmt.Fprintf(&sb, "User: %s\nAssistant: %s\n\n", t.Question, string(answer))
+	}
+	return strings.TrimSpace(sb.String())
+}
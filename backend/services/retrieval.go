@@ -0,0 +1,292 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"skillup-backend/db"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// snippetChars is the length of the chunk excerpt returned with a citation
+const snippetChars = 240
+
+// RetrievedChunk is a search hit together with the document it came from
+type RetrievedChunk struct {
+	ChunkID     string
+	DocumentID  string
+	Filename    string
+	ChunkText   string
+	PageStart   *int
+	PageEnd     *int
+	StartMs     *int // time range within a transcript
+	EndMs       *int
+	Distance    float64 // embedding distance to the query (lower is closer)
+	KeywordRank float64 // ts_rank_cd score; 0 when the chunk did not match the keywords
+	Score       float64 // fused reciprocal-rank score (higher is better)
+}
+
+// Citation is a numbered source as shown to the user and saved on ChatMessage.Sources
+type Citation struct {
+	Index      int     `json:"index"` // the [n] used in the answer
+	ChunkID    string  `json:"chunk_id"`
+	DocumentID string  `json:"document_id"`
+	Filename   string  `json:"filename"`
+	Page       *int    `json:"page,omitempty"` // page to open in the viewer
+	PageStart  *int    `json:"page_start,omitempty"`
+	PageEnd    *int    `json:"page_end,omitempty"`
+	StartMs    *int    `json:"start_ms,omitempty"` // position to seek to in a lecture recording
+	EndMs      *int    `json:"end_ms,omitempty"`
+	Snippet    string  `json:"snippet"`
+	Distance   float64 `json:"distance"`
+	Score      float64 `json:"score"`
+	Cited      bool    `json:"cited"` // referenced in the answer text
+}
+
+// SearchOptions tunes hybrid retrieval. Zero values fall back to defaults.
+type SearchOptions struct {
+	TopK          int      `json:"top_k"`
+	VectorWeight  *float64 `json:"vector_weight"`
+	KeywordWeight *float64 `json:"keyword_weight"`
+
+	// DocumentIDs restricts the search; nil searches every document (see ResolveScope)
+	DocumentIDs []string `json:"-"`
+}
+
+const (
+	defaultTopK = 5
+	maxTopK     = 20
+	// rrfK dampens the advantage of top ranks in reciprocal rank fusion;
+	// 60 is the value from the original RRF paper
+	rrfK = 60
+)
+
+// Validate checks request-supplied options, replacing an omitted (zero)
+// top_k with the default
+func (o *SearchOptions) Validate() error {
+	if o.TopK == 0 {
+		o.TopK = defaultTopK
+	}
+	if o.TopK < 1 || o.TopK > maxTopK {
+		return fmt.Errorf("top_k must be between 1 and %d", maxTopK)
+	}
+	if (o.VectorWeight != nil && *o.VectorWeight < 0) || (o.KeywordWeight != nil && *o.KeywordWeight < 0) {
+		return fmt.Errorf("weights must not be negative")
+	}
+	if o.VectorWeight != nil && o.KeywordWeight != nil && *o.VectorWeight == 0 && *o.KeywordWeight == 0 {
+		return fmt.Errorf("at least one of vector_weight and keyword_weight must be positive")
+	}
+	return nil
+}
+
+func (o SearchOptions) weights() (vector, keyword float64) {
+	vector, keyword = 1, 1
+	if o.VectorWeight != nil {
+		vector = *o.VectorWeight
+	}
+	if o.KeywordWeight != nil {
+		keyword = *o.KeywordWeight
+	}
+	return vector, keyword
+}
+
+// HybridSearch combines pgvector similarity with Postgres full-text search
+// using weighted reciprocal rank fusion: score = Σ weight / (rrfK + rank).
+func HybridSearch(userID, query string, queryEmbedding pgvector.Vector, opts SearchOptions) ([]RetrievedChunk, error) {
+	if opts.DocumentIDs != nil && len(opts.DocumentIDs) == 0 {
+		return nil, nil // scope matched no documents
+	}
+
+	topK := opts.TopK
+	if topK <= 0 {
+		topK = defaultTopK
+	}
+	vectorWeight, keywordWeight := opts.weights()
+
+	// Fetch deeper candidate lists than topK so fusion has something to work with
+	candidates := topK * 4
+	if candidates < 20 {
+		candidates = 20
+	}
+
+	fused := map[string]*RetrievedChunk{}
+	var order []string
+	add := func(hits []RetrievedChunk, weight float64) {
+		for rank, h := range hits {
+			hit, ok := fused[h.ChunkID]
+			if !ok {
+				h := h
+				hit = &h
+				fused[h.ChunkID] = hit
+				order = append(order, h.ChunkID)
+			}
+			if h.KeywordRank > hit.KeywordRank {
+				hit.KeywordRank = h.KeywordRank
+			}
+			hit.Score += weight / float64(rrfK+rank+1)
+		}
+	}
+
+	if vectorWeight > 0 {
+		hits, err := vectorSearch(userID, queryEmbedding, opts.DocumentIDs, candidates)
+		if err != nil {
+			return nil, err
+		}
+		add(hits, vectorWeight)
+	}
+	if keywordWeight > 0 && strings.TrimSpace(query) != "" {
+		hits, err := keywordSearch(userID, query, queryEmbedding, opts.DocumentIDs, candidates)
+		if err != nil {
+			return nil, err
+		}
+		add(hits, keywordWeight)
+	}
+
+	results := make([]RetrievedChunk, 0, len(order))
+	for _, id := range order {
+		results = append(results, *fused[id])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Distance < results[j].Distance
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// vectorSearch returns the user's chunks closest to the query embedding
+func vectorSearch(userID string, queryEmbedding pgvector.Vector, documentIDs []string, limit int) ([]RetrievedChunk, error) {
+	where, args := scopeFilter(userID, documentIDs)
+
+	var hits []RetrievedChunk
+	// NOTE: we use raw SQL ordering by distance using pgvector operator <->.
+	// GORM will map the param; pgvector-go implements driver.Valuer to pass vector.
+	err := db.DB.Raw(`
+		SELECT c.id AS chunk_id, c.document_id, d.filename, c.chunk_text,
+		       c.page_start, c.page_end, c.start_ms, c.end_ms, c.embedding <-> ? AS distance
+		FROM document_chunks c
+		JOIN documents d ON d.id = c.document_id
+		WHERE `+where+`
+		ORDER BY distance
+		LIMIT ?`, append(append([]interface{}{queryEmbedding}, args...), limit)...).Scan(&hits).Error
+	return hits, err
+}
+
+// keywordSearch ranks the user's chunks against the query with full-text search.
+// websearch_to_tsquery accepts free text, "quoted phrases" and -exclusions.
+func keywordSearch(userID, query string, queryEmbedding pgvector.Vector, documentIDs []string, limit int) ([]RetrievedChunk, error) {
+	where, args := scopeFilter(userID, documentIDs)
+
+	var hits []RetrievedChunk
+	err := db.DB.Raw(`
+		SELECT c.id AS chunk_id, c.document_id, d.filename, c.chunk_text,
+		       c.page_start, c.page_end, c.start_ms, c.end_ms, c.embedding <-> ? AS distance,
+		       ts_rank_cd(c.search_vector, q) AS keyword_rank
+		FROM document_chunks c
+		JOIN documents d ON d.id = c.document_id,
+		     websearch_to_tsquery('english', ?) q
+		WHERE `+where+` AND c.search_vector @@ q
+		ORDER BY keyword_rank DESC
+		LIMIT ?`, append(append([]interface{}{queryEmbedding, query}, args...), limit)...).Scan(&hits).Error
+	return hits, err
+}
+
+// scopeFilter builds the WHERE clause limiting chunks to the user (and scope)
+func scopeFilter(userID string, documentIDs []string) (string, []interface{}) {
+	if documentIDs == nil {
+		return "c.user_id = ?", []interface{}{userID}
+	}
+	return "c.user_id = ? AND c.document_id IN ?", []interface{}{userID, documentIDs}
+}
+
+// BuildContextFromChunks combines retrieved chunks into a numbered context string
+func BuildContextFromChunks(chunks []RetrievedChunk) string {
+	var parts []string
+	for i, c := range chunks {
+		parts = append(parts, fmt.Sprintf("[%d] %s\n%s", i+1, sourceLabel(c), c.ChunkText))
+	}
+	return strings.Join(parts, "\n\n---\n\n")
+}
+
+// BuildCitations numbers chunks in prompt order and flags those the answer cites
+func BuildCitations(chunks []RetrievedChunk, answer string) []Citation {
+	cited := citedIndexes(answer)
+	citations := make([]Citation, 0, len(chunks))
+	for i, c := range chunks {
+		citations = append(citations, Citation{
+			Index:      i + 1,
+			ChunkID:    c.ChunkID,
+			DocumentID: c.DocumentID,
+			Filename:   c.Filename,
+			Page:       c.PageStart,
+			PageStart:  c.PageStart,
+			PageEnd:    c.PageEnd,
+			StartMs:    c.StartMs,
+			EndMs:      c.EndMs,
+			Snippet:    snippet(c.ChunkText),
+			Distance:   c.Distance,
+			Score:      c.Score,
+			Cited:      cited[i+1],
+		})
+	}
+	return citations
+}
+
+// sourceLabel describes where a chunk came from, e.g. "biology.pdf, pp. 3-4"
+// or "lecture-3.vtt, 12:03-13:10"
+func sourceLabel(c RetrievedChunk) string {
+	if loc := locationLabel(c.PageStart, c.PageEnd, c.StartMs, c.EndMs); loc != "" {
+		return c.Filename + ", " + loc
+	}
+	return c.Filename
+}
+
+// locationLabel describes a chunk's position by page range or, for
+// transcripts, by time range; empty when neither is known
+func locationLabel(pageStart, pageEnd, startMs, endMs *int) string {
+	if pages := pageLabel(pageStart, pageEnd); pages != "" {
+		return pages
+	}
+	return timeLabel(startMs, endMs)
+}
+
+// pageLabel formats a page range as "p. 3" or "pp. 3-4"; empty when unknown
+func pageLabel(start, end *int) string {
+	switch {
+	case start != nil && end != nil && *end != *start:
+		return fmt.Sprintf("pp. %d-%d", *start, *end)
+	case start != nil:
+		return fmt.Sprintf("p. %d", *start)
+	}
+	return ""
+}
+
+var citationRef = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// citedIndexes collects n from every [n] or [n, m] in the answer
+func citedIndexes(answer string) map[int]bool {
+	cited := map[int]bool{}
+	for _, m := range citationRef.FindAllStringSubmatch(answer, -1) {
+		for _, n := range strings.Split(m[1], ",") {
+			if i, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+				cited[i] = true
+			}
+		}
+	}
+	return cited
+}
+
+func snippet(text string) string {
+	r := []rune(strings.TrimSpace(text))
+	if len(r) > snippetChars {
+		return string(r[:snippetChars]) + "…"
+	}
+	return string(r)
+}
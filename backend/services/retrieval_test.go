@@ -0,0 +1,85 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCitedIndexes(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+		want   map[int]bool
+	}{
+		{"none", "No sources here.", map[int]bool{}},
+		{"single", "Mitochondria make ATP [2].", map[int]bool{2: true}},
+		{"list", "Both agree [1, 3] and [3,4].", map[int]bool{1: true, 3: true, 4: true}},
+		{"repeated", "[1] then [1] again", map[int]bool{1: true}},
+		{"not a citation", "an array a[i] or [x] or [1-2]", map[int]bool{}},
+		{"multi digit", "see [12]", map[int]bool{12: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := citedIndexes(tt.answer); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("citedIndexes(%q) = %v, want %v", tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCitations(t *testing.T) {
+	page := 3
+	chunks := []RetrievedChunk{
+		{ChunkID: "a", Filename: "bio.pdf", ChunkText: "first", PageStart: &page, PageEnd: &page},
+		{ChunkID: "b", Filename: "bio.pdf", ChunkText: "second"},
+		{ChunkID: "c", Filename: "chem.pdf", ChunkText: "third"},
+	}
+	citations := BuildCitations(chunks, "Answer citing [1] and [3], not [7].")
+
+	wantCited := []bool{true, false, true}
+	if len(citations) != len(chunks) {
+		t.Fatalf("got %d citations, want %d", len(citations), len(chunks))
+	}
+	for i, c := range citations {
+		if c.Index != i+1 || c.ChunkID != chunks[i].ChunkID {
+			t.Errorf("citation %d = {%d %s}, want {%d %s}", i, c.Index, c.ChunkID, i+1, chunks[i].ChunkID)
+		}
+		if c.Cited != wantCited[i] {
+			t.Errorf("citation %d cited = %v, want %v", c.Index, c.Cited, wantCited[i])
+		}
+	}
+	if citations[0].Page == nil || *citations[0].Page != 3 {
+		t.Errorf("citation 1 page = %v, want 3", citations[0].Page)
+	}
+}
+
+func TestSearchOptionsValidate(t *testing.T) {
+	zero, one, minus := 0.0, 1.0, -1.0
+	tests := []struct {
+		name    string
+		opts    SearchOptions
+		wantK   int
+		wantErr bool
+	}{
+		{"omitted top_k uses the default", SearchOptions{}, defaultTopK, false},
+		{"smallest", SearchOptions{TopK: 1}, 1, false},
+		{"largest", SearchOptions{TopK: maxTopK}, maxTopK, false},
+		{"too large", SearchOptions{TopK: maxTopK + 1}, 0, true},
+		{"negative", SearchOptions{TopK: -1}, 0, true},
+		{"negative weight", SearchOptions{VectorWeight: &minus}, 0, true},
+		{"keyword only", SearchOptions{VectorWeight: &zero, KeywordWeight: &one}, defaultTopK, false},
+		{"both weights zero", SearchOptions{VectorWeight: &zero, KeywordWeight: &zero}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			err := opts.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && opts.TopK != tt.wantK {
+				t.Errorf("TopK = %d, want %d", opts.TopK, tt.wantK)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"skillup-backend/db"
+
+	"gorm.io/datatypes"
+)
+
+// ErrScopeNotFound means a scope referenced a document, collection or goal
+// the user does not own
+var ErrScopeNotFound = errors.New("document, collection or goal not found")
+
+// ChatScope restricts chat retrieval to a subset of the user's documents.
+// When several filters are set, only documents matching all of them are searched.
+type ChatScope struct {
+	DocumentIDs  []string `json:"document_ids,omitempty"`
+	CollectionID *string  `json:"collection_id,omitempty"`
+	GoalID       *string  `json:"goal_id,omitempty"`
+}
+
+// IsEmpty reports whether the scope covers every document
+func (s ChatScope) IsEmpty() bool {
+	return len(s.DocumentIDs) == 0 && isBlank(s.CollectionID) && isBlank(s.GoalID)
+}
+
+// ResolveScope turns a scope into the list of document IDs to search.
+// It returns nil for an empty scope (search everything) and a non-nil,
+// possibly empty, slice otherwise.
+func ResolveScope(userID string, scope ChatScope) ([]string, error) {
+	if scope.IsEmpty() {
+		return nil, nil
+	}
+
+	var sets [][]string
+
+	if len(scope.DocumentIDs) > 0 {
+		var ids []string
+		if err := db.DB.Model(&db.Document{}).
+			Where("user_id = ? AND id IN ?", userID, scope.DocumentIDs).
+			Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) != len(uniqueStrings(scope.DocumentIDs)) {
+			return nil, ErrScopeNotFound
+		}
+		sets = append(sets, ids)
+	}
+
+	if !isBlank(scope.CollectionID) {
+		var count int64
+		if err := db.DB.Model(&db.Collection{}).Where("id = ? AND user_id = ?", *scope.CollectionID, userID).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrScopeNotFound
+		}
+		var ids []string
+		if err := db.DB.Model(&db.CollectionDocument{}).
+			Where("collection_id = ?", *scope.CollectionID).
+			Pluck("document_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		sets = append(sets, ids)
+	}
+
+	if !isBlank(scope.GoalID) {
+		var count int64
+		if err := db.DB.Model(&db.Goal{}).Where("id = ? AND user_id = ?", *scope.GoalID, userID).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrScopeNotFound
+		}
+		var ids []string
+		if err := db.DB.Model(&db.GoalDocument{}).
+			Where("goal_id = ?", *scope.GoalID).
+			Pluck("document_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		sets = append(sets, ids)
+	}
+
+	return intersectStrings(sets), nil
+}
+
+// ScopeFromConversation reads the scope remembered on a conversation
+func ScopeFromConversation(conv *db.Conversation) ChatScope {
+	scope := ChatScope{
+		CollectionID: conv.ScopeCollectionID,
+		GoalID:       conv.ScopeGoalID,
+	}
+	if len(conv.ScopeDocumentIDs) > 0 {
+		_ = json.Unmarshal(conv.ScopeDocumentIDs, &scope.DocumentIDs)
+	}
+	return scope
+}
+
+// ApplyScopeToConversation stores scope on conv (not saved)
+func ApplyScopeToConversation(conv *db.Conversation, scope ChatScope) {
+	conv.ScopeDocumentIDs = nil
+	if len(scope.DocumentIDs) > 0 {
+		ids, _ := json.Marshal(uniqueStrings(scope.DocumentIDs))
+		conv.ScopeDocumentIDs = datatypes.JSON(ids)
+	}
+	conv.ScopeCollectionID = nil
+	if !isBlank(scope.CollectionID) {
+		conv.ScopeCollectionID = scope.CollectionID
+	}
+	conv.ScopeGoalID = nil
+	if !isBlank(scope.GoalID) {
+		conv.ScopeGoalID = scope.GoalID
+	}
+}
+
+func isBlank(s *string) bool {
+	return s == nil || *s == ""
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// intersectStrings returns the values present in every set
+func intersectStrings(sets [][]string) []string {
+	if len(sets) == 0 {
+		return nil
+	}
+	counts := map[string]int{}
+	for _, set := range sets {
+		for _, v := range uniqueStrings(set) {
+			counts[v]++
+		}
+	}
+	out := []string{}
+	for _, v := range uniqueStrings(sets[0]) {
+		if counts[v] == len(sets) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"skillup-backend/config"
+	"skillup-backend/db"
+	"skillup-backend/utils"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tokenCleanupInterval is how often expired refresh tokens and denylist entries are purged
+const tokenCleanupInterval = time.Hour
+
+// ErrInvalidRefreshToken covers unknown, expired, revoked and reused refresh tokens
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+// TokenPair is what login, signup and refresh return to the client
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// StartSession logs a user in on a new device: it starts a refresh token
+// family and issues the first token pair
+func StartSession(userID, userAgent string) (*TokenPair, error) {
+	var pair *TokenPair
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		pair, err = issueTokens(tx, userID, uuid.NewString(), userAgent)
+		return err
+	})
+	return pair, err
+}
+
+// RefreshSession rotates a refresh token: the presented token is marked used
+// and a new pair in the same family is issued. Presenting a token that was
+// already rotated means it was copied, so the whole family is revoked.
+func RefreshSession(refreshToken, userAgent string) (*TokenPair, error) {
+	var rt db.RefreshToken
+	err := db.DB.Where("token_hash = ?", utils.HashToken(refreshToken)).First(&rt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if rt.RevokedAt != nil || now.After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if rt.UsedAt != nil {
+		log.Printf("refresh token reuse for user %s, revoking session %s", rt.UserID, rt.FamilyID)
+		if err := RevokeSession(rt.UserID, rt.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	var pair *TokenPair
+	reused := false
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional update so two concurrent refreshes cannot both rotate it
+		res := tx.Model(&db.RefreshToken{}).Where("id = ? AND used_at IS NULL", rt.ID).Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			reused = true
+			return nil
+		}
+		var err error
+		pair, err = issueTokens(tx, rt.UserID, rt.FamilyID, userAgent)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		if err := RevokeSession(rt.UserID, rt.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	return pair, nil
+}
+
+// RevokeSession revokes every refresh token of a family and denylists the
+// access tokens issued with them
+func RevokeSession(userID, familyID string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeFamilies(tx, "user_id = ? AND family_id = ?", userID, familyID)
+	})
+}
+
+// RevokeAllSessions signs a user out everywhere
+func RevokeAllSessions(userID string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeFamilies(tx, "user_id = ?", userID)
+	})
+}
+
+// RevokeOtherSessions signs a user out everywhere except the given session
+func RevokeOtherSessions(userID, keepFamilyID string) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		return revokeFamilies(tx, "user_id = ? AND family_id <> ?", userID, keepFamilyID)
+	})
+}
+
+// RevokeAccessToken adds an access token to the denylist until it expires
+func RevokeAccessToken(userID, jti string, expiresAt time.Time) error {
+	return revokeAccessToken(db.DB, userID, jti, expiresAt)
+}
+
+// IsTokenRevoked reports whether an access token's jti is on the denylist
+func IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := db.DB.Model(&db.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// StartTokenCleanup periodically deletes expired refresh tokens, denylist
+// entries, emailed user tokens and abandoned sign-ins
+func StartTokenCleanup() {
+	go func() {
+		for {
+			purgeExpiredTokens()
+			time.Sleep(tokenCleanupInterval)
+		}
+	}()
+}
+
+func purgeExpiredTokens() {
+	now := time.Now()
+	if err := db.DB.Where("expires_at < ?", now).Delete(&db.RevokedToken{}).Error; err != nil {
+		log.Printf("token cleanup: %v", err)
+	}
+	if err := db.DB.Where("expires_at < ?", now).Delete(&db.RefreshToken{}).Error; err != nil {
+		log.Printf("token cleanup: %v", err)
+	}
+	if err := db.DB.Where("expires_at < ?", now).Delete(&db.UserToken{}).Error; err != nil {
+		log.Printf("token cleanup: %v", err)
+	}
+	if err := db.DB.Where("expires_at < ?", now).Delete(&db.OIDCLoginState{}).Error; err != nil {
+		log.Printf("token cleanup: %v", err)
+	}
+}
+
+// issueTokens creates a refresh token in the family and a matching access token
+func issueTokens(tx *gorm.DB, userID, familyID, userAgent string) (*TokenPair, error) {
+	access, claims, err := utils.GenerateJWT(userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	if err := tx.Create(&db.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refresh),
+		AccessJTI: claims.RegisteredClaims.ID,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(config.AppConfig.REFRESH_TOKEN_TTL),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(config.AppConfig.ACCESS_TOKEN_TTL.Seconds()),
+	}, nil
+}
+
+// revokeFamilies revokes the refresh tokens matching the condition and
+// denylists their access tokens that may still be valid
+func revokeFamilies(tx *gorm.DB, query string, args ...interface{}) error {
+	var tokens []db.RefreshToken
+	if err := tx.Where(query, args...).Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, t := range tokens {
+		if t.AccessJTI == "" {
+			continue
+		}
+		// access tokens are issued together with their refresh token
+		expires := t.CreatedAt.Add(config.AppConfig.ACCESS_TOKEN_TTL)
+		if expires.Before(now) {
+			continue
+		}
+		if err := revokeAccessToken(tx, t.UserID, t.AccessJTI, expires); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&db.RefreshToken{}).Where(query, args...).Where("revoked_at IS NULL").Update("revoked_at", now).Error
+}
+
+func revokeAccessToken(tx *gorm.DB, userID, jti string, expiresAt time.Time) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&db.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"skillup-backend/db"
+	"skillup-backend/utils"
+	"testing"
+)
+
+// accessJTI returns the jti of an access token
+func accessJTI(t *testing.T, token string) string {
+	t.Helper()
+	claims, err := utils.ValidateJWT(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims.RegisteredClaims.ID
+}
+
+func isRevoked(t *testing.T, jti string) bool {
+	t.Helper()
+	revoked, err := IsTokenRevoked(jti)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revoked
+}
+
+func TestRefreshSessionRotation(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t, "password")
+
+	first, err := StartSession(user.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshSession(first.RefreshToken, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("refresh did not issue a new token pair")
+	}
+	if accessJTI(t, second.AccessToken) == accessJTI(t, first.AccessToken) {
+		t.Error("the new access token reuses the old jti")
+	}
+
+	var old db.RefreshToken
+	if err := db.DB.Where("token_hash = ?", utils.HashToken(first.RefreshToken)).First(&old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if old.UsedAt == nil {
+		t.Error("the rotated refresh token was not marked used")
+	}
+	if isRevoked(t, accessJTI(t, first.AccessToken)) {
+		t.Error("a normal refresh denylisted the previous access token")
+	}
+
+	// The newest token keeps rotating
+	if _, err := RefreshSession(second.RefreshToken, "test"); err != nil {
+		t.Errorf("refreshing with the new token: %v", err)
+	}
+}
+
+func TestRefreshSessionReuse(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t, "password")
+
+	first, err := StartSession(user.ID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := StartSession(user.ID, "other device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := RefreshSession(first.RefreshToken, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Presenting the rotated token again means it was copied
+	if _, err := RefreshSession(first.RefreshToken, "attacker"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reused token: error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := RefreshSession(second.RefreshToken, "test"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("latest token of the family: error = %v, want ErrInvalidRefreshToken", err)
+	}
+	for name, token := range map[string]string{"first": first.AccessToken, "second": second.AccessToken} {
+		if !isRevoked(t, accessJTI(t, token)) {
+			t.Errorf("the %s access token of the family is not denylisted", name)
+		}
+	}
+
+	// Other sessions are untouched
+	if isRevoked(t, accessJTI(t, other.AccessToken)) {
+		t.Error("another session's access token was denylisted")
+	}
+	if _, err := RefreshSession(other.RefreshToken, "other device"); err != nil {
+		t.Errorf("another session: %v", err)
+	}
+}
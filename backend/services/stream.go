@@ -0,0 +1,47 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// maxStreamLine bounds a single SSE/NDJSON line from a provider
+const maxStreamLine = 1 << 20
+
+// readSSE calls onData with the payload of every "data:" line of a
+// server-sent event stream until the stream ends or onData returns done.
+func readSSE(r io.Reader, onData func(data []byte) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		done, err := onData(bytes.TrimSpace(line[len("data:"):]))
+		if err != nil || done {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// readNDJSON calls onLine with every non-empty line of a newline-delimited JSON stream
+func readNDJSON(r io.Reader, onLine func(line []byte) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLine)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		done, err := onLine(line)
+		if err != nil || done {
+			return err
+		}
+	}
+	return scanner.Err()
+}
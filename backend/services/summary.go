@@ -2,6 +2,11 @@ package services
 
 import (
 	"fmt"
+	"regexp"
+	"skillup-backend/db"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 // SummaryOptions represents options for document summarization
@@ -10,10 +15,130 @@ type SummaryOptions struct {
 	Style  string `json:"style"`  // bullet_points/paragraph/key_points
 }
 
-// SummarizeDocument generates a summary of document text using LLM
-func SummarizeDocument(text string, options SummaryOptions) (string, error) {
-	if text == "" {
-		return "", fmt.Errorf("document text is empty")
+// SummarySection is a contiguous part of the document summarized on its own
+type SummarySection struct {
+	ID        string `json:"id"`    // "S1", "S2", ... as used in source tags
+	Label     string `json:"label"` // human readable location, e.g. "pp. 12-18"
+	PageStart int    `json:"page_start,omitempty"`
+	PageEnd   int    `json:"page_end,omitempty"`
+	Text      string `json:"-"`
+}
+
+// SummaryPoint is one point (or sentence) of a summary and the sections it came from
+type SummaryPoint struct {
+	Text    string           `json:"text"`
+	Sources []SummarySection `json:"sources"`
+}
+
+// SummaryResult is a generated summary with per-point attribution
+type SummaryResult struct {
+	Summary  string         `json:"summary"`
+	Points   []SummaryPoint `json:"points"`
+	Sections int            `json:"sections"`
+}
+
+const (
+	// singlePassChars is the largest document summarized in one prompt
+	singlePassChars = 10000
+	// sectionChars is the target size of a section in the map step
+	sectionChars = 8000
+	// reduceChars is the largest batch of partial summaries combined at once
+	reduceChars = 12000
+	// summaryConcurrency bounds parallel LLM calls in the map step
+	summaryConcurrency = 4
+)
+
+// DocumentSections loads a document's text as summary sections. Documents
+// ingested before pages were stored fall back to their raw text.
+func DocumentSections(documentID string) ([]SummarySection, error) {
+	pages, err := loadPages(documentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) > 0 {
+		var doc db.Document
+		if err := db.DB.Select("id", "page_count").Where("id = ?", documentID).First(&doc).Error; err != nil {
+			return nil, err
+		}
+		// chapters and single-page text files have no page numbers to cite
+		if doc.PageCount == 0 {
+			return SectionsFromText(strings.Join(pages, "\n")), nil
+		}
+		return SectionsFromPages(pages), nil
+	}
+
+	var raw db.DocumentRaw
+	if err := db.DB.Where("document_id = ?", documentID).First(&raw).Error; err != nil {
+		return nil, err
+	}
+	return SectionsFromText(raw.Text), nil
+}
+
+// SectionsFromPages groups consecutive pages into sections of about sectionChars
+func SectionsFromPages(pages []string) []SummarySection {
+	var sections []SummarySection
+	var buf strings.Builder
+	start := 0
+
+	flush := func(end int) {
+		if strings.TrimSpace(buf.String()) != "" {
+			sections = append(sections, SummarySection{PageStart: start, PageEnd: end, Text: buf.String()})
+		}
+		buf.Reset()
+	}
+
+	for i, p := range pages {
+		page := i + 1
+		if buf.Len() > 0 && runeLen(buf.String())+runeLen(p) > sectionChars {
+			flush(page - 1)
+		}
+		if buf.Len() == 0 {
+			start = page
+		}
+		buf.WriteString(p)
+		buf.WriteString("\n")
+	}
+	flush(len(pages))
+
+	return labelSections(sections)
+}
+
+// SectionsFromText splits text without page information into sections of about sectionChars
+func SectionsFromText(text string) []SummarySection {
+	var sections []SummarySection
+	for _, c := range ChunkPages([]string{text}, ChunkOptions{Strategy: ChunkStrategyStructured, MaxTokens: sectionChars / 4}) {
+		sections = append(sections, SummarySection{Text: c.Text})
+	}
+	return labelSections(sections)
+}
+
+func labelSections(sections []SummarySection) []SummarySection {
+	for i := range sections {
+		s := &sections[i]
+		s.ID = "S" + strconv.Itoa(i+1)
+		switch {
+		case s.PageStart > 0 && s.PageEnd > s.PageStart:
+			s.Label = fmt.Sprintf("pp. %d-%d", s.PageStart, s.PageEnd)
+		case s.PageStart > 0:
+			s.Label = fmt.Sprintf("p. %d", s.PageStart)
+		default:
+			s.Label = fmt.Sprintf("part %d of %d", i+1, len(sections))
+		}
+	}
+	return sections
+}
+
+// SummarizeDocument summarizes a document. Short documents are summarized in
+// one pass; longer ones map-reduce: every section is summarized in parallel,
+// then the partial summaries are combined (hierarchically if needed). Every
+// point is tagged with the sections it came from.
+func SummarizeDocument(sections []SummarySection, options SummaryOptions) (*SummaryResult, error) {
+	total := 0
+	for _, s := range sections {
+		total += runeLen(strings.TrimSpace(s.Text))
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("document text is empty")
 	}
 
 	// Default options
@@ -24,6 +149,116 @@ func SummarizeDocument(text string, options SummaryOptions) (string, error) {
 		options.Style = "paragraph"
 	}
 
+	var material string
+	if total <= singlePassChars {
+		material = joinSections(sections, func(s SummarySection) string { return s.Text })
+	} else {
+		partials, err := summarizeSections(sections, options)
+		if err != nil {
+			return nil, err
+		}
+		material, err = reducePartials(partials)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := LLM(finalSummaryPrompt(material, options))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate summary: %w", err)
+	}
+
+	points := parseSummaryPoints(summary, options.Style, sections)
+	return &SummaryResult{
+		Summary:  strings.TrimSpace(sourceTags.ReplaceAllString(summary, "")),
+		Points:   points,
+		Sections: len(sections),
+	}, nil
+}
+
+// summarizeSections is the map step: one partial summary per section
+func summarizeSections(sections []SummarySection, options SummaryOptions) ([]SummarySection, error) {
+	words := map[string]int{"short": 60, "medium": 100, "long": 150}[options.Length]
+	if words == 0 {
+		words = 100
+	}
+
+	partials := make([]SummarySection, len(sections))
+	errs := make([]error, len(sections))
+	sem := make(chan struct{}, summaryConcurrency)
+	var wg sync.WaitGroup
+
+	for i, s := range sections {
+		wg.Add(1)
+		go func(i int, s SummarySection) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			prompt := fmt.Sprintf(`Summarize the following part of a document (%s) in at most %d words. Keep the key facts, definitions, formulas and conclusions. Do not add information that is not in the text.
+
+Text:
+%s`, s.Label, words, s.Text)
+
+			out, err := LLM(prompt)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to summarize %s: %w", s.Label, err)
+				return
+			}
+			partial := s
+			partial.Text = strings.TrimSpace(out)
+			partials[i] = partial
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return partials, nil
+}
+
+// reducePartials combines tagged partial summaries until they fit in one prompt.
+// Intermediate combinations keep the [S#] tags so attribution survives.
+func reducePartials(partials []SummarySection) (string, error) {
+	material := joinSections(partials, func(s SummarySection) string { return s.Text })
+	for runeLen(material) > reduceChars {
+		var batches [][]string
+		var cur []string
+		size := 0
+		for _, block := range strings.Split(material, "\n\n") {
+			if size+runeLen(block) > reduceChars && len(cur) > 0 {
+				batches = append(batches, cur)
+				cur, size = nil, 0
+			}
+			cur = append(cur, block)
+			size += runeLen(block)
+		}
+		batches = append(batches, cur)
+		if len(batches) == 1 {
+			// A single oversized block; nothing left to split
+			break
+		}
+
+		var combined []string
+		for _, batch := range batches {
+			prompt := fmt.Sprintf(`Combine the following partial summaries into one shorter summary. Each statement must keep the source tags (like [S3]) of the partial summaries it came from.
+
+%s`, strings.Join(batch, "\n\n"))
+			out, err := LLM(prompt)
+			if err != nil {
+				return "", fmt.Errorf("failed to combine summaries: %w", err)
+			}
+			combined = append(combined, strings.TrimSpace(out))
+		}
+		material = strings.Join(combined, "\n\n")
+	}
+	return material, nil
+}
+
+func finalSummaryPrompt(material string, options SummaryOptions) string {
 	// Determine word count based on length
 	wordCount := map[string]string{
 		"short":  "100-150 words",
@@ -36,44 +271,108 @@ func SummarizeDocument(text string, options SummaryOptions) (string, error) {
 		targetLength = "200-300 words"
 	}
 
-	// Truncate text if too long
-	maxChars := 10000
-	if len(text) > maxChars {
-		text = text[:maxChars] + "..."
-	}
-
-	var prompt string
+	const attribution = `The material is divided into sections tagged [S1], [S2], ... End every point or sentence with the tags of the sections it is based on, e.g. [S2] or [S1, S4].`
 
 	if options.Style == "bullet_points" {
-		prompt = fmt.Sprintf(`Summarize the following document as bullet points. Length: %s
+		return fmt.Sprintf(`Summarize the following document as bullet points. Length: %s
+
+%s
 
 Document:
 %s
 
-Format: Return key points as bullet points (•).`, targetLength, text)
+Format: Return key points as bullet points (•), one per line.`, targetLength, attribution, material)
 	} else if options.Style == "key_points" {
-		prompt = fmt.Sprintf(`Summarize the following document by extracting the key points. Length: %s
+		return fmt.Sprintf(`Summarize the following document by extracting the key points. Length: %s
+
+%s
 
 Document:
 %s
 
-Format: List the main key points in a clear, organized manner.`, targetLength, text)
-	} else {
-		// paragraph style
-		prompt = fmt.Sprintf(`Summarize the following document in a clear, concise paragraph. Length: %s
+Format: List the main key points in a clear, organized manner, one per line.`, targetLength, attribution, material)
+	}
+
+	// paragraph style
+	return fmt.Sprintf(`Summarize the following document in a clear, concise paragraph. Length: %s
+
+%s
 
 Document:
 %s
 
-Format: Write a comprehensive summary in paragraph form.`, targetLength, text)
+Format: Write a comprehensive summary in paragraph form.`, targetLength, attribution, material)
+}
+
+var sourceTags = regexp.MustCompile(`\s*\[(S\d+(?:\s*,\s*S\d+)*)\]`)
+
+// parseSummaryPoints splits the summary into points and resolves their source tags
+func parseSummaryPoints(summary, style string, sections []SummarySection) []SummaryPoint {
+	byID := make(map[string]SummarySection, len(sections))
+	for _, s := range sections {
+		byID[s.ID] = s
 	}
 
-	summary := LLM(prompt)
-	
-	if summary == "" || summary == "LLM request failed" {
-		return "", fmt.Errorf("failed to generate summary")
+	resolve := func(tags []string) []SummarySection {
+		sources := []SummarySection{}
+		seen := map[string]bool{}
+		for _, group := range tags {
+			for _, id := range strings.Split(group, ",") {
+				id = strings.TrimSpace(id)
+				if s, ok := byID[id]; ok && !seen[id] {
+					seen[id] = true
+					sources = append(sources, s)
+				}
+			}
+		}
+		return sources
 	}
 
-	return summary, nil
+	var points []SummaryPoint
+	addPoint := func(text string, tags []string) {
+		text = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text), "•-*"))
+		if text == "" {
+			return
+		}
+		points = append(points, SummaryPoint{Text: strings.TrimSpace(text), Sources: resolve(tags)})
+	}
+
+	if style == "bullet_points" || style == "key_points" {
+		for _, line := range strings.Split(summary, "\n") {
+			var tags []string
+			for _, m := range sourceTags.FindAllStringSubmatch(line, -1) {
+				tags = append(tags, m[1])
+			}
+			addPoint(sourceTags.ReplaceAllString(line, ""), tags)
+		}
+		return points
+	}
+
+	// Paragraphs: a point is the text up to each group of tags
+	last := 0
+	for _, m := range sourceTags.FindAllStringSubmatchIndex(summary, -1) {
+		text := summary[last:m[0]]
+		tags := []string{summary[m[2]:m[3]]}
+		last = m[1]
+		// keep sentence-ending punctuation that follows the tag
+		if last < len(summary) && strings.ContainsRune(".!?", rune(summary[last])) {
+			text += summary[last : last+1]
+			last++
+		}
+		addPoint(strings.TrimLeft(text, ".!? "), tags)
+	}
+	addPoint(strings.TrimLeft(summary[last:], ".!? "), nil)
+	return points
 }
 
+func joinSections(sections []SummarySection, text func(SummarySection) string) string {
+	parts := make([]string, 0, len(sections))
+	for _, s := range sections {
+		parts = append(parts, fmt.Sprintf("[%s] (%s)\n%s", s.ID, s.Label, text(s)))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSummaryPoints(t *testing.T) {
+	sections := []SummarySection{
+		{ID: "S1", Label: "pp. 1-4"},
+		{ID: "S2", Label: "pp. 5-9"},
+		{ID: "S3", Label: "pp. 10-12"},
+	}
+
+	// point is a summary point reduced to its text and source IDs
+	type point struct {
+		Text    string
+		Sources []string
+	}
+	tests := []struct {
+		name    string
+		style   string
+		summary string
+		want    []point
+	}{
+		{
+			name:    "bullets with tags",
+			style:   "bullet_points",
+			summary: "- Cells divide by mitosis [S1]\n• ATP powers the cell [S2, S3]\n\n* Untagged point",
+			want: []point{
+				{"Cells divide by mitosis", []string{"S1"}},
+				{"ATP powers the cell", []string{"S2", "S3"}},
+				{"Untagged point", []string{}},
+			},
+		},
+		{
+			name:    "key points with repeated and unknown tags",
+			style:   "key_points",
+			summary: "Enzymes lower activation energy [S2] [S2, S9]",
+			want:    []point{{"Enzymes lower activation energy", []string{"S2"}}},
+		},
+		{
+			name:    "paragraph split at tags keeps punctuation",
+			style:   "paragraph",
+			summary: "Cells divide [S1]. Energy comes from ATP [S2, S3]! Trailing text without a tag.",
+			want: []point{
+				{"Cells divide.", []string{"S1"}},
+				{"Energy comes from ATP!", []string{"S2", "S3"}},
+				{"Trailing text without a tag.", []string{}},
+			},
+		},
+		{
+			name:    "paragraph without tags",
+			style:   "detailed",
+			summary: "Just one sentence.",
+			want:    []point{{"Just one sentence.", []string{}}},
+		},
+		{
+			name:    "empty",
+			style:   "bullet_points",
+			summary: "\n\n",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []point
+			for _, p := range parseSummaryPoints(tt.summary, tt.style, sections) {
+				ids := []string{}
+				for _, s := range p.Sources {
+					ids = append(ids, s.ID)
+				}
+				got = append(got, point{p.Text, ids})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSummaryPoints() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,33 +1,50 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"skillup-backend/config"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// Claims of an access token. RegisteredClaims.ID is the jti checked against
+// the revocation denylist; SessionID ties the token to its refresh token family.
 type Claims struct {
-	ID string `json:"id"`
+	ID        string `json:"id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(id string) (string, error) {
+// GenerateJWT issues a short-lived access token for a user session
+func GenerateJWT(id, sessionID string) (string, *Claims, error) {
+	now := time.Now()
 	claims := &Claims{
-		ID: id,
+		ID:        id,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(60 * 24 * time.Hour)), // 60 days
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(config.AppConfig.ACCESS_TOKEN_TTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JWT_SECRET))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.AppConfig.JWT_SECRET))
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
 }
 
 func ValidateJWT(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.AppConfig.JWT_SECRET), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 	if err != nil {
 		return nil, err
 	}
@@ -38,3 +55,26 @@ func ValidateJWT(tokenString string) (*Claims, error) {
 	}
 	return claims, nil
 }
+
+// NewOpaqueToken returns a random URL-safe token (256 bits), e.g. a refresh token
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex SHA-256 under which an opaque token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignToken returns the hex HMAC-SHA256 of an opaque token keyed with the
+// server secret, so stored tokens cannot be forged or used without it
+func SignToken(token string) string {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWT_SECRET))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}